@@ -0,0 +1,76 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+)
+
+// datePattern matches the dates accepted by /decklists/by_date
+var datePattern = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+
+// codePattern keeps pack codes from escaping the data directory
+var codePattern = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// The stand-in serves the same directory layout that marvel.ReplayClient reads
+// (and marvel.Recorder writes): "<data>/packs.json", "<data>/cards.json",
+// "<data>/cards/<pack>.json" and "<data>/decklists/by_date/<date>.json".
+func main() {
+	dataDir := flag.String("data", "marvelcdb-data", "directory of recorded marvelcdb responses")
+	addr := flag.String("addr", ":8080", "address to listen on")
+	flag.Parse()
+
+	if _, err := os.Stat(*dataDir); err != nil {
+		log.Fatalln(err)
+	}
+
+	log.Println("Serving marvelcdb stand-in data from", *dataDir)
+
+	router := gin.Default()
+	api := router.Group("/api/public")
+
+	api.GET("/packs", func(c *gin.Context) {
+		serveFile(c, filepath.Join(*dataDir, "packs.json"), http.StatusNotFound)
+	})
+	api.GET("/cards", func(c *gin.Context) {
+		serveFile(c, filepath.Join(*dataDir, "cards.json"), http.StatusNotFound)
+	})
+	api.GET("/cards/:pack", func(c *gin.Context) {
+		pack := c.Param("pack")
+		if !codePattern.MatchString(pack) {
+			c.Status(http.StatusNotFound)
+			return
+		}
+		serveFile(c, filepath.Join(*dataDir, "cards", pack+".json"), http.StatusNotFound)
+	})
+	api.GET("/decklists/by_date/:date", func(c *gin.Context) {
+		date := c.Param("date")
+		if !datePattern.MatchString(date) {
+			c.Status(http.StatusNotFound)
+			return
+		}
+		// marvelcdb returns a 500 on days that have no decks
+		serveFile(c, filepath.Join(*dataDir, "decklists", "by_date", date+".json"), http.StatusInternalServerError)
+	})
+
+	router.Run(*addr)
+}
+
+// serveFile responds with the JSON file at path, or with missingStatus if it doesn't exist
+func serveFile(c *gin.Context, path string, missingStatus int) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		c.Status(missingStatus)
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Data(http.StatusOK, "application/json", data)
+}
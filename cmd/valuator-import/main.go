@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/colbymilton/marchamps-valuator/internal/controller"
+)
+
+// valuator-import sends a MarvelCDB collection export or deck list to a running valuator
+// and prints the owned pack codes to use for the "owned" query, e.g.
+//
+//	valuator-import -server http://localhost:9999 my-deck.json
+//
+// The export is read from stdin if no file is given.
+func main() {
+	server := flag.String("server", "http://localhost:9999", "address of the valuator")
+	flag.Parse()
+
+	var data []byte
+	var err error
+	if flag.NArg() > 0 {
+		data, err = os.ReadFile(flag.Arg(0))
+	} else {
+		data, err = io.ReadAll(os.Stdin)
+	}
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	resp, err := http.Post(strings.TrimRight(*server, "/")+"/import", "application/json", bytes.NewReader(data))
+	if err != nil {
+		log.Fatalln(err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		log.Fatalln("import failed:", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	result := &controller.ImportResult{}
+	if err := json.Unmarshal(body, result); err != nil {
+		log.Fatalln(err)
+	}
+
+	if len(result.UnknownPacks) > 0 {
+		log.Println("Unknown pack codes:", strings.Join(result.UnknownPacks, ", "))
+	}
+	if len(result.UnknownCards) > 0 {
+		log.Println("Unknown card codes:", strings.Join(result.UnknownCards, ", "))
+	}
+	fmt.Println(strings.Join(result.Owned, ","))
+}
@@ -0,0 +1,300 @@
+package controller
+
+import (
+	"math"
+	"sort"
+
+	"github.com/colbymilton/marchamps-valuator/internal/utils"
+)
+
+// cMaxBudgetSearchNodes limits how many partial plans SuggestBudgetPlan looks at
+// the best plan found so far is returned when the limit is reached
+const cMaxBudgetSearchNodes = 50000
+
+// packValuation holds the stored pack values and heroes, so that packs can be valued
+// against different owned packs without reading them from storage again
+type packValuation struct {
+	v         *Valuator
+	opts      ValuationOptions
+	pvs       []*PackValue
+	allHeroes []*Hero
+
+	// hero is the chosen hero in hero mode
+	hero *Hero
+}
+
+func (v *Valuator) newPackValuation(opts ValuationOptions) (*packValuation, error) {
+	pvs, err := v.db.GetPackValues()
+	if err != nil {
+		return nil, err
+	}
+	allHeroes, err := v.db.GetHeroes()
+	if err != nil {
+		return nil, err
+	}
+	pval := &packValuation{v: v, opts: opts, pvs: pvs, allHeroes: allHeroes}
+
+	// in hero mode, everything is valued for the chosen hero alone
+	if opts.HeroCode != "" {
+		cvs := []*CardValue{}
+		for _, pv := range pvs {
+			cvs = append(cvs, pv.CardValues...)
+		}
+		hero, err := v.applyHeroMode(cvs, opts.HeroCode, allHeroes)
+		if err != nil {
+			return nil, err
+		}
+		pval.hero = hero
+		pval.allHeroes = []*Hero{hero}
+	}
+
+	return pval, nil
+}
+
+// ownedHeroes returns the heroes in the owned packs, or the chosen hero in hero mode
+func (pval *packValuation) ownedHeroes(owned []string) map[string]*Hero {
+	if pval.hero != nil {
+		return map[string]*Hero{pval.hero.Code: pval.hero}
+	}
+	ownedHeroes := map[string]*Hero{}
+	for _, hero := range pval.allHeroes {
+		if utils.StringsContains(owned, hero.PackCode) {
+			ownedHeroes[hero.Code] = hero
+		}
+	}
+	return ownedHeroes
+}
+
+// value sets the card values and value sum of the pack for the owned copies and heroes
+func (pval *packValuation) value(pv *PackValue, ownedCopies map[string]int, ownedHeroes map[string]*Hero) {
+	for _, cv := range pv.CardValues {
+		adjustCardValue(cv, ownedCopies, ownedHeroes, pval.allHeroes, pv.Code, pval.opts)
+	}
+	pv.Price = pval.v.prices[pv.Code]
+	pv.Calculate()
+}
+
+// valueBound returns the most the pack can add once more packs are owned.
+// Owning more copies only lowers NewMod and popularity and weights don't depend on what is owned,
+// but owning more heroes can raise TraitMod, so it is counted at its highest (1).
+func valueBound(pv *PackValue) int {
+	bound := 0
+	for _, cv := range pv.CardValues {
+		bound += int(math.Max(0, math.Round(100*cv.NewMod*cv.PopularityMod*cv.WeightMod)))
+	}
+	return bound
+}
+
+// SuggestBudgetPlan handles the /budget_plan endpoint
+// it finds the packs with the most marginal value that fit the budget, valuing every pack
+// with the packs before it in the plan owned, so that cards shared between packs are only counted once.
+// The search is a branch and bound over the priced packs that aren't owned, starting from the plan
+// that greedily buys the best value per price. If it has to stop early, the plan isn't Optimal.
+func (v *Valuator) SuggestBudgetPlan(owned []string, budget float64, opts ValuationOptions) (*BudgetPlan, error) {
+	if len(v.prices) == 0 {
+		return nil, ErrNoPrices
+	}
+	if err := v.updateIfNeeded(); err != nil {
+		return nil, err
+	}
+
+	// owned is compared to the stored pack codes below
+	owned, err := v.validateOwned(owned)
+	if err != nil {
+		return nil, err
+	}
+
+	bs, err := v.newBudgetSearch(owned, budget, opts)
+	if err != nil {
+		return nil, err
+	}
+	bs.greedy(budget)
+	bs.search(0, budget, 0)
+
+	// the chosen packs are bought again in order to explain every step
+	plan := &BudgetPlan{Budget: budget, Optimal: bs.nodes < cMaxBudgetSearchNodes, Steps: []*PlanStep{}}
+	for step, code := range bs.best {
+		c := bs.candidate(code)
+		bs.buy(c)
+		pv := c.pv.copy()
+		sort.Slice(pv.CardValues, func(i, j int) bool { return pv.CardValues[i].Value > pv.CardValues[j].Value })
+
+		plan.TotalPrice += pv.Price
+		plan.TotalValue += pv.ValueSum
+		plan.Steps = append(plan.Steps, &PlanStep{
+			Step:            step + 1,
+			PackValue:       pv,
+			MarginalValue:   pv.ValueSum,
+			CumulativeValue: plan.TotalValue,
+		})
+	}
+
+	return plan, nil
+}
+
+// budgetCandidate is a pack that a budget plan can buy
+type budgetCandidate struct {
+	pv     *PackValue
+	heroes []*Hero
+	// copies is how many copies of each card the pack adds
+	copies map[string]int
+	bound  int
+}
+
+// budgetSearch is the state of a branch and bound search for the best budget plan
+type budgetSearch struct {
+	pval       *packValuation
+	candidates []*budgetCandidate
+
+	ownedCopies map[string]int
+	ownedHeroes map[string]*Hero
+	chosen      []string
+
+	best      []string
+	bestValue int
+	nodes     int
+}
+
+func (v *Valuator) newBudgetSearch(owned []string, budget float64, opts ValuationOptions) (*budgetSearch, error) {
+	pval, err := v.newPackValuation(opts)
+	if err != nil {
+		return nil, err
+	}
+	ownedCopies, err := v.getOwnedCopies(owned)
+	if err != nil {
+		return nil, err
+	}
+	bs := &budgetSearch{pval: pval, ownedCopies: ownedCopies, ownedHeroes: pval.ownedHeroes(owned), best: []string{}}
+
+	for _, pv := range pval.pvs {
+		price := v.prices[pv.Code]
+		if price <= 0 || price > budget || utils.StringsContains(owned, pv.Code) {
+			continue
+		}
+
+		pval.value(pv, bs.ownedCopies, bs.ownedHeroes)
+		candidate := &budgetCandidate{pv: pv, copies: map[string]int{}, bound: valueBound(pv)}
+		for _, cv := range pv.CardValues {
+			candidate.copies[cv.Code] = cv.Card.CopiesIn(pv.Code)
+		}
+		// the chosen hero is the only owned hero in hero mode
+		if pval.hero == nil {
+			for _, hero := range pval.allHeroes {
+				if hero.PackCode == pv.Code {
+					candidate.heroes = append(candidate.heroes, hero)
+				}
+			}
+		}
+		bs.candidates = append(bs.candidates, candidate)
+	}
+
+	// the best bound per price first, so that the fractional bound only has to look ahead once
+	sort.SliceStable(bs.candidates, func(i, j int) bool {
+		a, b := bs.candidates[i], bs.candidates[j]
+		return float64(a.bound)/a.pv.Price > float64(b.bound)/b.pv.Price
+	})
+
+	return bs, nil
+}
+
+func (bs *budgetSearch) candidate(code string) *budgetCandidate {
+	for _, c := range bs.candidates {
+		if c.pv.Code == code {
+			return c
+		}
+	}
+	return nil
+}
+
+// buy adds the candidate to the owned packs and returns the value it added
+func (bs *budgetSearch) buy(c *budgetCandidate) int {
+	bs.pval.value(c.pv, bs.ownedCopies, bs.ownedHeroes)
+	for code, copies := range c.copies {
+		bs.ownedCopies[code] += copies
+	}
+	for _, hero := range c.heroes {
+		bs.ownedHeroes[hero.Code] = hero
+	}
+	bs.chosen = append(bs.chosen, c.pv.Code)
+	return c.pv.ValueSum
+}
+
+// unbuy undoes the last buy
+func (bs *budgetSearch) unbuy(c *budgetCandidate) {
+	for code, copies := range c.copies {
+		bs.ownedCopies[code] -= copies
+	}
+	for _, hero := range c.heroes {
+		delete(bs.ownedHeroes, hero.Code)
+	}
+	bs.chosen = bs.chosen[:len(bs.chosen)-1]
+}
+
+// greedy buys the pack with the best value per price until nothing that adds value fits,
+// which gives the search a good plan to beat
+func (bs *budgetSearch) greedy(budget float64) {
+	bought := []*budgetCandidate{}
+	value := 0
+	for {
+		var best *budgetCandidate
+		bestScore := 0.0
+		for _, c := range bs.candidates {
+			if c.pv.Price > budget || utils.StringsContains(bs.chosen, c.pv.Code) {
+				continue
+			}
+			bs.pval.value(c.pv, bs.ownedCopies, bs.ownedHeroes)
+			if c.pv.ValueSum > 0 && c.pv.ValuePerPrice > bestScore {
+				best, bestScore = c, c.pv.ValuePerPrice
+			}
+		}
+		if best == nil {
+			break
+		}
+		value += bs.buy(best)
+		budget -= best.pv.Price
+		bought = append(bought, best)
+	}
+
+	bs.best = append([]string{}, bs.chosen...)
+	bs.bestValue = value
+	for i := len(bought) - 1; i >= 0; i-- {
+		bs.unbuy(bought[i])
+	}
+}
+
+// search decides whether to buy each candidate from i onwards, keeping the best plan it finds
+func (bs *budgetSearch) search(i int, budget float64, value int) {
+	if value > bs.bestValue {
+		bs.best = append([]string{}, bs.chosen...)
+		bs.bestValue = value
+	}
+	if i == len(bs.candidates) || bs.nodes >= cMaxBudgetSearchNodes {
+		return
+	}
+	if float64(value)+bs.bound(i, budget) <= float64(bs.bestValue) {
+		return // nothing below this can beat the best plan
+	}
+	bs.nodes++
+
+	c := bs.candidates[i]
+	if c.pv.Price <= budget {
+		added := bs.buy(c)
+		bs.search(i+1, budget-c.pv.Price, value+added)
+		bs.unbuy(c)
+	}
+	bs.search(i+1, budget, value)
+}
+
+// bound returns the most that the candidates from i onwards can add within the budget,
+// allowing a fraction of a pack to be bought
+func (bs *budgetSearch) bound(i int, budget float64) float64 {
+	total := 0.0
+	for _, c := range bs.candidates[i:] {
+		if c.pv.Price > budget {
+			return total + float64(c.bound)*budget/c.pv.Price
+		}
+		total += float64(c.bound)
+		budget -= c.pv.Price
+	}
+	return total
+}
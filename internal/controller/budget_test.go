@@ -0,0 +1,178 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// testPackValue returns the value of a pack holding one copy of each card, each worth 100 unowned
+func testPackValue(code string, cardCodes ...string) *PackValue {
+	pv := &PackValue{Code: code}
+	for _, cardCode := range cardCodes {
+		card := &Card{Code: cardCode, PackCodes: []string{code}, PackQuantities: map[string]int{code: 1}}
+		pv.CardValues = append(pv.CardValues, &CardValue{Code: cardCode, Card: card, TypicalCopies: 1})
+	}
+	return pv
+}
+
+func TestBudgetSearch(t *testing.T) {
+	tests := []struct {
+		name   string
+		pvs    []*PackValue
+		prices map[string]float64
+		budget float64
+		want   []string
+		value  int
+	}{
+		{
+			name:   "greedy isn't enough",
+			pvs:    []*PackValue{testPackValue("a", "a1", "a2", "a3"), testPackValue("b", "b1", "b2", "b3", "b4"), testPackValue("c", "c1", "c2", "c3", "c4")},
+			prices: map[string]float64{"a": 11, "b": 15, "c": 15},
+			budget: 30,
+			want:   []string{"b", "c"},
+			value:  800,
+		},
+		{
+			name:   "shared cards are counted once",
+			pvs:    []*PackValue{testPackValue("a", "a1", "a2", "a3"), testPackValue("b", "x1", "x2", "x3", "x4"), testPackValue("c", "x1", "x2", "x3", "x4")},
+			prices: map[string]float64{"a": 11, "b": 15, "c": 15},
+			budget: 30,
+			want:   []string{"a", "b"},
+			value:  700,
+		},
+		{
+			name:   "packs without a price are never bought",
+			pvs:    []*PackValue{testPackValue("a", "a1"), testPackValue("b", "b1", "b2")},
+			prices: map[string]float64{"a": 10},
+			budget: 100,
+			want:   []string{"a"},
+			value:  100,
+		},
+		{
+			name:   "nothing fits",
+			pvs:    []*PackValue{testPackValue("a", "a1")},
+			prices: map[string]float64{"a": 10},
+			budget: 5,
+			want:   []string{},
+			value:  0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := NewMemoryStorage()
+			if err := db.SavePackValues(tt.pvs); err != nil {
+				t.Fatal(err)
+			}
+			v := NewValuatorWith(db, nil)
+			v.prices = tt.prices
+
+			bs, err := v.newBudgetSearch(nil, tt.budget, ValuationOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			bs.greedy(tt.budget)
+			bs.search(0, tt.budget, 0)
+			if !reflect.DeepEqual(bs.best, tt.want) || bs.bestValue != tt.value {
+				t.Errorf("best plan = %q worth %v, want %q worth %v", bs.best, bs.bestValue, tt.want, tt.value)
+			}
+		})
+	}
+}
+
+func TestBudgetSearchMatchesExhaustiveSearch(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for round := 0; round < 20; round++ {
+		// packs share cards from a small pool, so buying one lowers the value of others
+		pvs := []*PackValue{}
+		prices := map[string]float64{}
+		for i := 0; i < 8; i++ {
+			code := fmt.Sprintf("p%v", i)
+			cardCodes := []string{}
+			for _, c := range rng.Perm(12)[:1+rng.Intn(5)] {
+				cardCodes = append(cardCodes, fmt.Sprintf("c%v", c))
+			}
+			pvs = append(pvs, testPackValue(code, cardCodes...))
+			prices[code] = float64(5 + rng.Intn(20))
+		}
+		budget := float64(20 + rng.Intn(40))
+
+		db := NewMemoryStorage()
+		if err := db.SavePackValues(pvs); err != nil {
+			t.Fatal(err)
+		}
+		v := NewValuatorWith(db, nil)
+		v.prices = prices
+		bs, err := v.newBudgetSearch(nil, budget, ValuationOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// the value of every affordable set of packs
+		want := 0
+		for set := 0; set < 1<<len(bs.candidates); set++ {
+			bought := []*budgetCandidate{}
+			price, value := 0.0, 0
+			for i, c := range bs.candidates {
+				if set&(1<<i) != 0 {
+					price += c.pv.Price
+					value += bs.buy(c)
+					bought = append(bought, c)
+				}
+			}
+			for i := len(bought) - 1; i >= 0; i-- {
+				bs.unbuy(bought[i])
+			}
+			if price <= budget && value > want {
+				want = value
+			}
+		}
+
+		bs.greedy(budget)
+		bs.search(0, budget, 0)
+		if bs.bestValue != want {
+			t.Errorf("round %v: best plan %q is worth %v, want %v", round, bs.best, bs.bestValue, want)
+		}
+	}
+}
+
+func TestSuggestBudgetPlan(t *testing.T) {
+	v := newTestValuator(t)
+
+	if _, err := v.SuggestBudgetPlan(nil, 30, ValuationOptions{}); !errors.Is(err, ErrNoPrices) {
+		t.Errorf("SuggestBudgetPlan without prices: err = %v, want ErrNoPrices", err)
+	}
+
+	v.prices = map[string]float64{"core": 20, "hulk": 10}
+	tests := []struct {
+		owned  []string
+		budget float64
+		want   string
+	}{
+		{nil, 15, "hulk:100"},
+		{nil, 25, "core:400"},
+		// with the core set bought first, the hulk's ally can be played by a genius hero
+		{nil, 30, "core:400 hulk:100"},
+		{[]string{"core"}, 30, "hulk:100"},
+	}
+
+	for _, tt := range tests {
+		plan, err := v.SuggestBudgetPlan(tt.owned, tt.budget, ValuationOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := ""
+		for _, step := range plan.Steps {
+			if got != "" {
+				got += " "
+			}
+			got += fmt.Sprintf("%v:%v", step.PackValue.Code, step.MarginalValue)
+		}
+		if got != tt.want || !plan.Optimal {
+			t.Errorf("SuggestBudgetPlan(%q, %v) = %q (optimal %v), want %q", tt.owned, tt.budget, got, plan.Optimal, tt.want)
+		}
+	}
+}
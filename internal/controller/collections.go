@@ -0,0 +1,178 @@
+package controller
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	cShareCodeAlphabet = "abcdefghjkmnpqrstuvwxyz023456789"
+	cShareCodeLength   = 7
+	cShareCodeAttempts = 5
+)
+
+// GetCollection returns the saved collection with the given id
+// the id is all that is needed to change or delete a collection, so collections can't be listed
+func (v *Valuator) GetCollection(id string) (*Collection, error) {
+	coll, err := v.db.GetCollection(id)
+	if err != nil {
+		return nil, err
+	}
+	if coll == nil {
+		return nil, notFoundError("collection-not-found", "unknown collection: %v", id)
+	}
+	return coll, nil
+}
+
+// CreateCollection saves a new collection with a generated id
+func (v *Valuator) CreateCollection(coll *Collection) (*Collection, error) {
+	if err := v.validateCollection(coll); err != nil {
+		return nil, err
+	}
+
+	id, err := newCollectionId()
+	if err != nil {
+		return nil, err
+	}
+	coll.Id = id
+	coll.ShareCode = ""
+	coll.Created = time.Now().UTC()
+	coll.Updated = coll.Created
+
+	if err := v.db.SaveCollection(coll); err != nil {
+		return nil, err
+	}
+	return coll, nil
+}
+
+// UpdateCollection replaces the contents of an existing collection
+func (v *Valuator) UpdateCollection(id string, coll *Collection) (*Collection, error) {
+	old, err := v.GetCollection(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := v.validateCollection(coll); err != nil {
+		return nil, err
+	}
+
+	coll.Id = old.Id
+	coll.ShareCode = old.ShareCode
+	coll.Created = old.Created
+	coll.Updated = time.Now().UTC()
+
+	if err := v.db.SaveCollection(coll); err != nil {
+		return nil, err
+	}
+	return coll, nil
+}
+
+// DeleteCollection removes a saved collection
+func (v *Valuator) DeleteCollection(id string) error {
+	deleted, err := v.db.DeleteCollection(id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return notFoundError("collection-not-found", "unknown collection: %v", id)
+	}
+	return nil
+}
+
+// ShareCollection gives the collection a share code, if it doesn't have one yet
+func (v *Valuator) ShareCollection(id string) (*Collection, error) {
+	coll, err := v.GetCollection(id)
+	if err != nil {
+		return nil, err
+	}
+	if coll.ShareCode != "" {
+		return coll, nil
+	}
+
+	// codes are short, so make sure a new one isn't already taken
+	for i := 0; i < cShareCodeAttempts && coll.ShareCode == ""; i++ {
+		code, err := newShareCode()
+		if err != nil {
+			return nil, err
+		}
+		taken, err := v.db.GetCollectionByShareCode(code)
+		if err != nil {
+			return nil, err
+		}
+		if taken == nil {
+			coll.ShareCode = code
+		}
+	}
+	if coll.ShareCode == "" {
+		return nil, fmt.Errorf("could not find a free share code")
+	}
+
+	if err := v.db.SaveCollection(coll); err != nil {
+		return nil, err
+	}
+	return coll, nil
+}
+
+// ValueSharedCollection handles the /share/:code endpoint
+// it values the packs for the collection with the share code
+func (v *Valuator) ValueSharedCollection(code string, opts ValuationOptions) (*SharedValuation, error) {
+	coll, err := v.db.GetCollectionByShareCode(code)
+	if err != nil {
+		return nil, err
+	}
+	if coll == nil {
+		return nil, notFoundError("share-code-not-found", "unknown share code: %v", code)
+	}
+
+	owned, opts := coll.Apply(nil, opts)
+	pvs, err := v.ValueAllPacks(owned, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// the id allows changing the collection, so it isn't shared
+	coll.Id = ""
+	return &SharedValuation{Collection: coll, PackValues: pvs}, nil
+}
+
+// validateCollection checks the fields of a collection sent by a user and normalises the owned pack codes
+func (v *Valuator) validateCollection(coll *Collection) error {
+	coll.Name = strings.TrimSpace(coll.Name)
+	if coll.Name == "" {
+		return validationError("invalid-collection", "a collection needs a name")
+	}
+	if coll.PopularityHalfLife != nil && *coll.PopularityHalfLife < 0 {
+		return validationError("invalid-collection", "popularityHalfLife can't be negative")
+	}
+
+	owned, err := v.validateOwned(coll.Owned)
+	if err != nil {
+		return err
+	}
+	coll.Owned = owned
+
+	return nil
+}
+
+// newShareCode returns a random code made of letters and digits that can't be mixed up
+func newShareCode() (string, error) {
+	b := make([]byte, cShareCodeLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	for i := range b {
+		b[i] = cShareCodeAlphabet[int(b[i])%len(cShareCodeAlphabet)]
+	}
+	return string(b), nil
+}
+
+// newCollectionId returns a random id for a collection
+func newCollectionId() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,53 @@
+package controller
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCollections(t *testing.T) {
+	v := newTestValuator(t)
+
+	coll, err := v.CreateCollection(&Collection{Name: " Mine ", Owned: []string{"Core"}, ShareCode: "taken"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if coll.Id == "" || coll.Name != "Mine" || !reflect.DeepEqual(coll.Owned, []string{"core"}) || coll.ShareCode != "" {
+		t.Errorf("CreateCollection() = %+v, want an id, a trimmed name, normalised packs and no share code", coll)
+	}
+
+	if _, err := v.UpdateCollection(coll.Id, &Collection{Name: "Mine", Owned: []string{"core", "hulk"}}); err != nil {
+		t.Fatal(err)
+	}
+	shared, err := v.ShareCollection(coll.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(shared.ShareCode) != cShareCodeLength {
+		t.Errorf("share code %q isn't %v characters", shared.ShareCode, cShareCodeLength)
+	}
+
+	// the id allows changing the collection, so a shared valuation doesn't include it
+	sv, err := v.ValueSharedCollection(shared.ShareCode, ValuationOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sv.Collection.Id != "" || !reflect.DeepEqual(sv.Collection.Owned, []string{"core", "hulk"}) {
+		t.Errorf("shared collection = %+v, want no id and the updated packs", sv.Collection)
+	}
+	for _, pv := range sv.PackValues {
+		if pv.ValueSum != 0 {
+			t.Errorf("%v value = %v with every pack owned, want 0", pv.Code, pv.ValueSum)
+		}
+	}
+
+	if err := v.DeleteCollection(coll.Id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := v.GetCollection(coll.Id); err == nil {
+		t.Error("GetCollection() found a deleted collection")
+	}
+	if kind, code := Classify(v.DeleteCollection(coll.Id)); kind != KindNotFound || code != "collection-not-found" {
+		t.Errorf("deleting twice = %v %v, want a collection-not-found error", kind, code)
+	}
+}
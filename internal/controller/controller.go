@@ -1,85 +1,155 @@
 package controller
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"os"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	marvel "github.com/colbymilton/marchamps-valuator/internal/marvelcdb"
 	"github.com/colbymilton/marchamps-valuator/internal/utils"
-	mw "github.com/colbymilton/marchamps-valuator/pkg/mongoWrapper"
-	"go.mongodb.org/mongo-driver/bson"
+	"golang.org/x/sync/errgroup"
 )
 
 const (
-	cPacks      = "packs"
-	cCards      = "cards"
-	cDecks      = "decks"
-	cHeroes     = "heroes"
-	cCardValues = "card-values"
-	cPackValues = "pack-values"
-	cMeta       = "meta"
-
 	cMetaId     = 1
 	cUpdateFreq = time.Hour * 6
+
+	cDefaultDeckWorkers = 4
+	cDefaultBoltPath    = "marchamps-valuator.db"
+	cDefaultDeckLimit   = 3
+	cDaysPerMonth       = 30.44
+
+	// cMaxExplainedDecks is how many of the most recent eligible decks a card explanation lists
+	cMaxExplainedDecks = 50
 )
 
 type Valuator struct {
-	mCli *marvel.MarvelClient
-	db   *mw.MongoDB
-
-	cards map[string]*Card
-	mutex sync.Mutex
+	mCli marvel.Client
+	db   Storage
+
+	cards    map[string]*Card
+	mutex    sync.Mutex
+	updating bool
+
+	// catalogueStored is true once the current catalogue has been converted and stored
+	catalogueStored bool
+
+	// decksFrom is the first day that decklists are fetched for
+	decksFrom     time.Time
+	deckWorkers   int
+	halfLife      float64
+	prices        map[string]float64
+	progress      UpdateProgress
+	progressMutex sync.Mutex
 }
 
+// NewValuator returns a Valuator configured from the environment
 func NewValuator() *Valuator {
-	v := &Valuator{cards: make(map[string]*Card)}
-
-	mcli, err := marvel.NewClient()
-	if err != nil {
-		log.Fatalln(err)
+	var mcli marvel.Client
+	if replayDir := os.Getenv("MARVELCDB_REPLAY_DIR"); replayDir != "" {
+		rcli, err := marvel.NewReplayClient(replayDir)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		mcli = rcli
+	} else {
+		mc, err := marvel.NewClient()
+		if err != nil {
+			log.Fatalln(err)
+		}
+		mcli = mc
+	}
+	if recordDir := os.Getenv("MARVELCDB_RECORD_DIR"); recordDir != "" {
+		mcli = marvel.NewRecorder(mcli, recordDir)
 	}
-	v.mCli = mcli
 
-	mongoConnStr := os.Getenv("MONGO_CONN_STRING")
-	v.db = mw.NewMongoDB(mongoConnStr, "marchamps-valuator")
+	var db Storage
+	switch backend := os.Getenv("STORAGE_BACKEND"); backend {
+	case "", "mongo":
+		mongoConnStr := os.Getenv("MONGO_CONN_STRING")
+		db = NewMongoStorage(mongoConnStr, "marchamps-valuator")
+	case "memory":
+		db = NewMemoryStorage()
+	case "bolt":
+		boltPath := os.Getenv("BOLT_DB_PATH")
+		if boltPath == "" {
+			boltPath = cDefaultBoltPath
+		}
+		db = NewBoltStorage(boltPath)
+	default:
+		log.Fatalln("unknown storage backend:", backend)
+	}
 
 	if os.Getenv("DELETE_ALL_ON_STARTUP") == "true" {
-		v.db.EmptyCollection(cCards)
-		v.db.EmptyCollection(cPacks)
-		v.db.EmptyCollection(cHeroes)
-		v.db.EmptyCollection(cCardValues)
-		v.db.EmptyCollection(cPackValues)
+		db.Clear()
+	}
+
+	v := NewValuatorWith(db, mcli)
+	decksFrom, err := time.Parse("2006-01-02", os.Getenv("DECKLISTS_FROM_TIME"))
+	if err != nil {
+		log.Fatalln(fmt.Errorf("DECKLISTS_FROM_TIME must be a date like 2020-01-01: %w", err))
+	}
+	v.decksFrom = decksFrom
+	if workers, err := strconv.Atoi(os.Getenv("DECKLISTS_WORKERS")); err == nil && workers > 0 {
+		v.deckWorkers = workers
+	}
+	if halfLife, err := strconv.ParseFloat(os.Getenv("POPULARITY_HALF_LIFE_MONTHS"), 64); err == nil && halfLife > 0 {
+		v.halfLife = halfLife
+	}
+	if pricesFile := os.Getenv("PACK_PRICES_FILE"); pricesFile != "" {
+		prices, err := loadPackPrices(pricesFile)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		v.prices = prices
 	}
 
 	return v
 }
 
+// NewValuatorWith returns a Valuator that uses the given storage and marvelcdb client
+func NewValuatorWith(db Storage, mcli marvel.Client) *Valuator {
+	return &Valuator{
+		mCli:        mcli,
+		db:          db,
+		cards:       make(map[string]*Card),
+		deckWorkers: cDefaultDeckWorkers,
+	}
+}
+
 // ValueAllCards handles the /card_values endpoint
-func (v *Valuator) ValueAllCards(owned []string) ([]*CardValue, error) {
+func (v *Valuator) ValueAllCards(owned []string, opts ValuationOptions) ([]*CardValue, error) {
 	if err := v.updateIfNeeded(); err != nil {
 		return nil, err
 	}
 
+	owned, err := v.validateOwned(owned)
+	if err != nil {
+		return nil, err
+	}
+
 	// grab base card values from db
-	cvs, err := mw.GetMany[CardValue](v.db, cCardValues, mw.BsonNoneD, mw.BsonNoneM)
+	cvs, err := v.db.GetCardValues()
 	if err != nil {
 		return nil, err
 	}
 
-	// make map of owned cards
-	ownedCards, err := v.getCardsFromPacks(owned)
+	// make map of owned card copies
+	ownedCopies, err := v.getOwnedCopies(owned)
 	if err != nil {
 		return nil, err
 	}
 
 	// get all heroes
-	allHeroes, err := mw.GetMany[Hero](v.db, cHeroes, mw.BsonNoneD, mw.BsonNoneM)
+	allHeroes, err := v.db.GetHeroes()
 	if err != nil {
 		return nil, err
 	}
@@ -92,9 +162,19 @@ func (v *Valuator) ValueAllCards(owned []string) ([]*CardValue, error) {
 		}
 	}
 
+	// in hero mode, everything is valued for the chosen hero alone
+	if opts.HeroCode != "" {
+		hero, err := v.applyHeroMode(cvs, opts.HeroCode, allHeroes)
+		if err != nil {
+			return nil, err
+		}
+		ownedHeroes = map[string]*Hero{hero.Code: hero}
+		allHeroes = []*Hero{hero}
+	}
+
 	// modify base pack values based on owned cards
 	for _, cv := range cvs {
-		adjustCardValue(cv, ownedCards, ownedHeroes, allHeroes, "", map[string]float64{})
+		adjustCardValue(cv, ownedCopies, ownedHeroes, allHeroes, "", opts)
 	}
 
 	sort.Slice(cvs, func(i, j int) bool { return cvs[i].Value > cvs[j].Value })
@@ -103,49 +183,257 @@ func (v *Valuator) ValueAllCards(owned []string) ([]*CardValue, error) {
 }
 
 // ValueAllPacks handles the /pack_values endpoint
-func (v *Valuator) ValueAllPacks(owned []string, aspectWeights map[string]float64) ([]*PackValue, error) {
+func (v *Valuator) ValueAllPacks(owned []string, opts ValuationOptions) ([]*PackValue, error) {
 	if err := v.updateIfNeeded(); err != nil {
 		return nil, err
 	}
 
+	owned, err := v.validateOwned(owned)
+	if err != nil {
+		return nil, err
+	}
+
 	// grab base pack values from db
-	pvs, err := mw.GetMany[PackValue](v.db, cPackValues, mw.BsonNoneD, mw.BsonNoneM)
+	pval, err := v.newPackValuation(opts)
 	if err != nil {
 		return nil, err
 	}
 
-	// make map of owned cards
-	ownedCards, err := v.getCardsFromPacks(owned)
+	// make map of owned card copies
+	ownedCopies, err := v.getOwnedCopies(owned)
 	if err != nil {
 		return nil, err
 	}
 
-	// get all heroes
-	allHeroes, err := mw.GetMany[Hero](v.db, cHeroes, mw.BsonNoneD, mw.BsonNoneM)
+	// modify base pack values based on owned cards
+	ownedHeroes := pval.ownedHeroes(owned)
+	pvs := pval.pvs
+	for _, pv := range pvs {
+		pval.value(pv, ownedCopies, ownedHeroes)
+		sort.Slice(pv.CardValues, func(i, j int) bool { return pv.CardValues[i].Value > pv.CardValues[j].Value })
+	}
+
+	SortPackValues(pvs, SortByValue)
+
+	return pvs, nil
+}
+
+// ExplainCardValue handles the /cards/:code/value endpoint
+// it values a single card like /card_values does and explains which heroes and decks were counted
+func (v *Valuator) ExplainCardValue(code string, owned []string, opts ValuationOptions) (*CardExplanation, error) {
+	cvs, err := v.ValueAllCards(owned, opts)
 	if err != nil {
 		return nil, err
 	}
 
-	// make map of owned heroes
-	ownedHeroes := map[string]*Hero{}
+	// owned is compared to the heroes' pack codes below
+	owned, err = v.validateOwned(owned)
+	if err != nil {
+		return nil, err
+	}
+
+	// duplicates are valued as the card they duplicate
+	var cv *CardValue
+	for _, c := range cvs {
+		if c.Code == code || utils.StringsContains(c.Card.DuplicateBy, code) {
+			cv = c
+			break
+		}
+	}
+	if cv == nil {
+		return nil, notFoundError("card-not-found", "unknown card: %v", code)
+	}
+
+	allHeroes, err := v.db.GetHeroes()
+	if err != nil {
+		return nil, err
+	}
+	decks, err := v.db.GetDecks()
+	if err != nil {
+		return nil, err
+	}
+
+	// the heroes the card was valued against, as in ValueAllCards
+	ownedHeroes := []*Hero{}
+	if opts.HeroCode != "" {
+		for _, hero := range allHeroes {
+			if heroKey(hero.Code) == heroKey(opts.HeroCode) {
+				ownedHeroes = []*Hero{hero}
+				break
+			}
+		}
+	} else {
+		for _, hero := range allHeroes {
+			if utils.StringsContains(owned, hero.PackCode) {
+				ownedHeroes = append(ownedHeroes, hero)
+			}
+		}
+	}
+
+	exp := &CardExplanation{
+		CardValue:       cv,
+		MatchedHeroes:   []*Hero{},
+		UnmatchedHeroes: []*HeroExclusion{},
+		EligibleDecks:   []*DeckSummary{},
+	}
+	for _, hero := range ownedHeroes {
+		if reason := heroLockReason(cv.Card, hero); reason != "" {
+			exp.UnmatchedHeroes = append(exp.UnmatchedHeroes, &HeroExclusion{Hero: hero, Reason: reason})
+		} else {
+			exp.MatchedHeroes = append(exp.MatchedHeroes, hero)
+		}
+	}
+
+	heroesByCode := map[string]*Hero{}
 	for _, hero := range allHeroes {
-		if utils.StringsContains(owned, hero.PackCode) {
-			ownedHeroes[hero.Code] = hero
+		heroesByCode[heroKey(hero.Code)] = hero
+	}
+	for _, deck := range decks {
+		if opts.HeroCode != "" && heroKey(deck.HeroCode) != heroKey(opts.HeroCode) {
+			continue
+		}
+		hero := heroesByCode[heroKey(deck.HeroCode)]
+		if hero == nil {
+			return nil, fmt.Errorf("could not find hero from decklist")
+		}
+
+		if eligible, _ := isCardEligibleForDeck(cv.Card, deck, hero); !eligible {
+			continue
+		}
+		copies := deck.Slots[cv.Card.Code]
+		for _, dup := range cv.Card.DuplicateBy {
+			copies += deck.Slots[dup]
 		}
+		exp.EligibleDecks = append(exp.EligibleDecks, &DeckSummary{
+			Id:          deck.Id,
+			HeroCode:    deck.HeroCode,
+			DateUpdated: deck.DateUpdated(),
+			Copies:      copies,
+		})
+	}
+	sort.Slice(exp.EligibleDecks, func(i, j int) bool { return exp.EligibleDecks[i].DateUpdated.After(exp.EligibleDecks[j].DateUpdated) })
+	exp.EligibleDecksTotal = len(exp.EligibleDecks)
+	if len(exp.EligibleDecks) > cMaxExplainedDecks {
+		exp.EligibleDecks = exp.EligibleDecks[:cMaxExplainedDecks]
 	}
 
-	// modify base pack values based on owned cards
-	for _, pv := range pvs {
-		for _, cv := range pv.CardValues {
-			adjustCardValue(cv, ownedCards, ownedHeroes, allHeroes, pv.Code, aspectWeights)
+	exp.Breakdown = explainBreakdown(cv, exp)
+
+	return exp, nil
+}
+
+// explainBreakdown describes each modifier of the card value in words
+func explainBreakdown(cv *CardValue, exp *CardExplanation) []string {
+	lines := []string{
+		fmt.Sprintf("value %v = 100 x new %.2f x popularity %.2f x heroes %.2f x weight %.2f",
+			cv.Value, cv.NewMod, cv.PopularityMod, cv.TraitMod, cv.WeightMod),
+		fmt.Sprintf("new: %v copies owned and %v added, a typical deck runs %v",
+			cv.OwnedCopies, cv.AddedCopies, cv.TypicalCopies),
+		fmt.Sprintf("popularity: in %v of %v eligible decks", cv.InDecksCount, cv.EligibleDecksCount),
+	}
+	if cv.EligibleDecksWeight > 0 {
+		lines = append(lines, fmt.Sprintf("popularity by recency: %.2f of %.2f weighted eligible decks",
+			cv.InDecksWeight, cv.EligibleDecksWeight))
+	}
+
+	for _, reason := range exclusionReasons {
+		if count := cv.ExcludedDecks[reason]; count > 0 {
+			lines = append(lines, fmt.Sprintf("%v decks excluded: %v", count, reason.Describe(cv.Card)))
 		}
-		sort.Slice(pv.CardValues, func(i, j int) bool { return pv.CardValues[i].Value > pv.CardValues[j].Value })
-		pv.Calculate()
 	}
 
-	sort.Slice(pvs, func(i, j int) bool { return pvs[i].ValueSum > pvs[j].ValueSum })
+	if len(cv.Card.LockingTraits) > 0 || len(cv.Card.LockingNames) > 0 {
+		names := []string{}
+		for _, hero := range exp.MatchedHeroes {
+			names = append(names, hero.Name)
+		}
+		lines = append(lines, fmt.Sprintf("heroes: %v of %v heroes can play it (%v)",
+			cv.OwnedHeroCount, cv.EligibleHeroCount, strings.Join(names, ", ")))
+	}
+	if cv.WeightMod != 1 {
+		lines = append(lines, fmt.Sprintf("weight: %.2f from the requested weights", cv.WeightMod))
+	}
+
+	return lines
+}
 
-	return pvs, nil
+// PlanPurchases handles the /purchase_plan endpoint
+// it repeatedly buys the most valuable pack and values the remaining packs again with it owned,
+// so the plan accounts for overlap between packs
+func (v *Valuator) PlanPurchases(owned []string, count int, opts ValuationOptions) ([]*PlanStep, error) {
+	// owned is compared to the stored pack codes below
+	owned, err := v.validateOwned(owned)
+	if err != nil {
+		return nil, err
+	}
+	plan := []*PlanStep{}
+	cumulative := 0
+	for step := 1; step <= count; step++ {
+		pvs, err := v.ValueAllPacks(owned, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		// packs are sorted by value, so the first one that isn't owned is the best buy
+		var best *PackValue
+		for _, pv := range pvs {
+			if !utils.StringsContains(owned, pv.Code) {
+				best = pv
+				break
+			}
+		}
+		if best == nil || best.ValueSum <= 0 {
+			break // nothing left that adds value
+		}
+
+		cumulative += best.ValueSum
+		plan = append(plan, &PlanStep{
+			Step:            step,
+			PackValue:       best,
+			MarginalValue:   best.ValueSum,
+			CumulativeValue: cumulative,
+		})
+		owned = append(owned, best.Code)
+	}
+
+	return plan, nil
+}
+
+// applyHeroMode recounts the decks of the card values using only the decks of the given hero
+// and returns that hero, so that trait and name locks can be checked against it alone
+func (v *Valuator) applyHeroMode(cvs []*CardValue, heroCode string, allHeroes []*Hero) (*Hero, error) {
+	var hero *Hero
+	for _, h := range allHeroes {
+		if strings.EqualFold(heroKey(h.Code), heroKey(heroCode)) {
+			hero = h
+			break
+		}
+	}
+	if hero == nil {
+		return nil, validationError("unknown-hero", "unknown hero: %v", heroCode)
+	}
+
+	allDecks, err := v.db.GetDecks()
+	if err != nil {
+		return nil, err
+	}
+	heroDecks := []*marvel.Decklist{}
+	for _, deck := range allDecks {
+		if heroKey(deck.HeroCode) == heroKey(hero.Code) {
+			heroDecks = append(heroDecks, deck)
+		}
+	}
+
+	heroesByCode := map[string]*Hero{heroKey(hero.Code): hero}
+	now := time.Now()
+	for _, cv := range cvs {
+		if err := countDecks(cv, heroDecks, heroesByCode); err != nil {
+			return nil, err
+		}
+		cv.CalculateDeckWeights(v.halfLife, now)
+	}
+
+	return hero, nil
 }
 
 // GetPacks handles the /packs endpoint
@@ -153,20 +441,36 @@ func (v *Valuator) GetPacks() ([]*marvel.Pack, error) {
 	if err := v.updateIfNeeded(); err != nil {
 		return nil, err
 	}
-	return mw.GetMany[marvel.Pack](v.db, cPacks, mw.BsonNoneD, bson.M{"availablestr": 1})
+	return v.db.GetPacks()
+}
+
+// GetStatus handles the /status endpoint
+func (v *Valuator) GetStatus() (*UpdateProgress, error) {
+	// the status explains why the valuator isn't ready, so those errors are left to it
+	if err := v.updateIfNeeded(); err != nil {
+		if kind, _ := Classify(err); kind != KindInitialising && kind != KindUpstreamUnavailable {
+			return nil, err
+		}
+	}
+
+	meta, err := v.db.GetMeta()
+	if err != nil {
+		return nil, err
+	}
+
+	v.progressMutex.Lock()
+	defer v.progressMutex.Unlock()
+	progress := v.progress
+	progress.LastUpdated = meta.LastUpdated
+	return &progress, nil
 }
 
 func (v *Valuator) updateIfNeeded() error {
 	v.mutex.Lock()
 	defer v.mutex.Unlock()
 
-	// add meta data (if it already exists, this will be ignored)
-	if err := mw.CreateMany[Meta](v.db, cMeta, []*Meta{{Id: cMetaId, LastUpdated: time.Time{}}}); err != nil {
-		return err
-	}
-
 	// get meta data from db
-	meta, err := mw.GetOne[Meta](v.db, cMeta, mw.BuildEqualsFilter("_id", cMetaId), mw.BsonNoneM)
+	meta, err := v.db.GetMeta()
 	if err != nil {
 		return err
 	}
@@ -174,67 +478,118 @@ func (v *Valuator) updateIfNeeded() error {
 	setup := meta.LastUpdated.IsZero()
 	needsUpdated := meta.LastUpdated.Add(cUpdateFreq).Before(time.Now())
 
-	if setup {
-		// since this is a first-time setup, we need to block until the database has been initialized
-		return v.updateAll()
-
-	} else if needsUpdated {
-		// since we already have some data, we can do the update in the background
+	// updates always happen in the background so that requests can still be answered
+	if needsUpdated && !v.updating {
+		v.updating = true
 		go func() {
-			if err := v.updateAll(); err != nil {
+			err := v.updateAll()
+			v.finishProgress(err)
+
+			v.mutex.Lock()
+			v.updating = false
+			v.mutex.Unlock()
+
+			if err != nil {
 				log.Println("error when updating in the background:", err)
 			}
 		}()
-		return nil
+	}
+
+	if setup {
+		// since this is a first-time setup, there is nothing to respond with until the update is done
+		v.progressMutex.Lock()
+		defer v.progressMutex.Unlock()
+		// the update is retried on every request, so report why the last attempt failed
+		if marvel.IsUpstreamError(v.progress.err) {
+			return NewError(KindUpstreamUnavailable, "marvelcdb-unavailable",
+				fmt.Errorf("the valuator could not be set up, marvelcdb is unavailable: %w", v.progress.err))
+		}
+		if v.progress.err != nil {
+			return fmt.Errorf("%w (last attempt failed: %v)", ErrInitialising, v.progress.LastError)
+		}
+		return fmt.Errorf("%w (%v of %v days of decklists fetched)", ErrInitialising, v.progress.DaysDone, v.progress.DaysTotal)
 	}
 
 	// doesn't need to be updated at all
 	return nil
 }
 
+// setStage records the stage that the current update has reached
+func (v *Valuator) setStage(stage string) {
+	v.progressMutex.Lock()
+	defer v.progressMutex.Unlock()
+
+	v.progress.Updating = true
+	v.progress.Stage = stage
+}
+
+// finishProgress records the end of an update
+func (v *Valuator) finishProgress(err error) {
+	v.progressMutex.Lock()
+	defer v.progressMutex.Unlock()
+
+	v.progress.Updating = false
+	v.progress.Stage = ""
+	v.progress.LastError = ""
+	v.progress.err = err
+	if err != nil {
+		v.progress.LastError = err.Error()
+	}
+}
+
 func (v *Valuator) updateAll() error {
-	// check mongo first, just to save a marvel endpoint call
+	// check storage first, just to save a marvel endpoint call
 	if err := v.db.Ping(); err != nil {
 		return err
 	}
 
 	// update packs
+	v.setStage("packs")
 	if err := v.updatePacks(); err != nil {
 		return err
 	}
 
 	// update cards
-	if err := v.updateCards(); err != nil {
+	v.setStage("cards")
+	catalogueChanged, err := v.updateCards()
+	if err != nil {
 		return err
 	}
 
-	// update heroes
-	if err := v.updateHeroes(); err != nil {
-		return err
+	// update heroes (they are built from the cards, so only if those changed)
+	if catalogueChanged {
+		v.setStage("heroes")
+		if err := v.updateHeroes(); err != nil {
+			return err
+		}
+		v.catalogueStored = true
 	}
 
 	// update decks
+	v.setStage("decks")
 	decksAdded, err := v.updateDecks()
 	if err != nil {
 		return err
 	}
 
-	// update card values
-	if decksAdded {
+	// update card values (they hold a copy of their card, so also if the catalogue changed)
+	if decksAdded || catalogueChanged {
+		v.setStage("card values")
 		if err := v.updateCardValues(); err != nil {
 			return err
 		}
 	}
 
 	// update pack values
-	if decksAdded {
+	if decksAdded || catalogueChanged {
+		v.setStage("pack values")
 		if err := v.updatePackValues(); err != nil {
 			return err
 		}
 	}
 
 	// update lastUpdated time
-	return mw.ReplaceOneID[Meta](v.db, cMeta, &Meta{Id: cMetaId, LastUpdated: time.Now()})
+	return v.db.SetMeta(&Meta{Id: cMetaId, LastUpdated: time.Now()})
 }
 
 func (v *Valuator) updatePacks() error {
@@ -244,18 +599,30 @@ func (v *Valuator) updatePacks() error {
 		return err
 	}
 
-	// defer log.Println("Local pack count:", mw.GetCollectionSize(v.db, cPacks))
-
-	return mw.CreateMany(v.db, cPacks, packs)
+	return v.db.AddPacks(packs)
 }
 
-func (v *Valuator) updateCards() error {
+// updateCards rebuilds the local list of cards, returning false if the catalogue was unchanged
+func (v *Valuator) updateCards() (changed bool, err error) {
 	log.Println("Updating local list of cards.")
 
+	// get latest cards
+	mCards, err := v.mCli.GetAllCards()
+	if err != nil {
+		return false, err
+	}
+
+	// nothing needs to be rebuilt if the catalogue hasn't changed since we last stored it
+	if tracker, ok := v.mCli.(marvel.ChangeTracker); ok && v.catalogueStored && !tracker.CatalogueChanged() {
+		log.Println("Card catalogue is unchanged.")
+		return false, nil
+	}
+	v.catalogueStored = false
+
 	// get packs
-	packs, err := mw.GetAll[marvel.Pack](v.db, cPacks)
+	packs, err := v.db.GetPacks()
 	if err != nil {
-		return err
+		return false, err
 	}
 
 	// build pack map
@@ -264,12 +631,6 @@ func (v *Valuator) updateCards() error {
 		packMap[pack.Code] = pack
 	}
 
-	// get latest cards
-	mCards, err := v.mCli.GetAllCards()
-	if err != nil {
-		return err
-	}
-
 	// convert marvel api cards to local cards
 	dups := make([]*marvel.Card, 0)
 	for _, mCard := range mCards {
@@ -279,19 +640,22 @@ func (v *Valuator) updateCards() error {
 		}
 
 		card := &Card{
-			Code:          mCard.Code,
-			Name:          mCard.Name,
-			Subname:       mCard.SubName,
-			PackCodes:     []string{mCard.PackCode},
-			TypeCode:      mCard.TypeCode,
-			Aspect:        mCard.FactionCode,
-			Traits:        strings.Split(mCard.Traits, ". "),
-			LockingTraits: parseLockingTraits(mCard.Text),
-			DateAvailable: packMap[mCard.PackCode].DateAvailable(),
-			DuplicateBy:   []string{},
-			Text:          mCard.Text,
-			CardSetName:   mCard.CardSetName,
-			ImageSrc:      mCard.ImageSrc,
+			Code:           mCard.Code,
+			Name:           mCard.Name,
+			Subname:        mCard.SubName,
+			PackCodes:      []string{mCard.PackCode},
+			TypeCode:       mCard.TypeCode,
+			Aspect:         mCard.FactionCode,
+			Traits:         strings.Split(mCard.Traits, ". "),
+			LockingTraits:  parseLockingTraits(mCard.Text),
+			LockingNames:   parseLockingNames(mCard.Text),
+			DateAvailable:  packMap[mCard.PackCode].DateAvailable(),
+			PackQuantities: map[string]int{mCard.PackCode: mCard.Quantity},
+			DeckLimit:      mCard.DeckLimit,
+			DuplicateBy:    []string{},
+			Text:           mCard.Text,
+			CardSetName:    mCard.CardSetName,
+			ImageSrc:       mCard.ImageSrc,
 		}
 
 		if mCard.LinkedCard != nil {
@@ -305,23 +669,23 @@ func (v *Valuator) updateCards() error {
 		// get the original card
 		oCard := v.cards[dup.DuplicateOf]
 		if oCard == nil {
-			return fmt.Errorf("could not find duplicate card")
+			return false, fmt.Errorf("could not find duplicate card")
 		}
 		oCard.PackCodes = append(oCard.PackCodes, dup.PackCode)
+		oCard.PackQuantities[dup.PackCode] += dup.Quantity
 		oCard.DuplicateBy = append(oCard.DuplicateBy, dup.Code)
 		v.cards[dup.Code] = oCard // point to the same card
 	}
 
-	// defer log.Println("Local card count:", mw.GetCollectionSize(v.db, cCards))
-
-	return mw.CreateMany(v.db, cCards, v.getUniqueCards())
+	// cards are replaced so that stored cards pick up any fields added since they were stored
+	return true, v.db.SaveCards(v.getUniqueCards())
 }
 
 func (v *Valuator) updateHeroes() error {
 	log.Println("Updating local list of heroes.")
 
 	// get hero cards
-	identityCards, err := mw.GetMany[Card](v.db, cCards, mw.BuildEqualsFilter("aspect", "hero"), mw.BsonNoneM)
+	identityCards, err := v.db.GetCardsByAspect("hero")
 	if err != nil {
 		return err
 	}
@@ -365,7 +729,7 @@ func (v *Valuator) updateHeroes() error {
 	heroes := []*Hero{}
 	for _, hero := range heroesBy {
 		// add possible granted traits from hero cards to hero
-		heroCards, err := mw.GetMany[Card](v.db, cCards, mw.BuildEqualsFilter("cardsetname", hero.Name), mw.BsonNoneM)
+		heroCards, err := v.db.GetCardsBySetName(hero.Name)
 		if err != nil {
 			return err
 		}
@@ -380,74 +744,131 @@ func (v *Valuator) updateHeroes() error {
 		heroes = append(heroes, hero)
 	}
 
-	// defer log.Println("Local hero count:", mw.GetCollectionSize(v.db, cHeroes))
-
-	return mw.CreateMany(v.db, cHeroes, heroes)
+	return v.db.AddHeroes(heroes)
 }
 
 func (v *Valuator) updateDecks() (isNewDecks bool, err error) {
 	log.Println("Updating local list of decks.")
 
-	isNewDecks = false
-
-	// default latest time to our starting date
-	latestTime, _ := time.Parse("2006-01-02", os.Getenv("DECKLISTS_FROM_TIME"))
+	// without a starting date, every day since year 1 would be fetched
+	startTime := v.decksFrom
+	if startTime.IsZero() {
+		return false, errors.New("no date to fetch decklists from (DECKLISTS_FROM_TIME)")
+	}
 
-	// get the latest deck we have stored and update latest time if needed
-	deck, err := mw.GetOne[marvel.Decklist](v.db, cDecks, mw.BsonNoneD, bson.M{"datecreatedstr": -1})
+	// find the days that have already been fetched
+	deckDays, err := v.db.GetDeckDays()
 	if err != nil {
 		return false, err
 	}
-	if deck != nil {
-		latestTime = deck.DateCreated()
-	}
-
-	// get decks since latest time
-	for {
-		if latestTime.After(time.Now()) {
-			break
+	completed := map[string]bool{}
+	for _, deckDay := range deckDays {
+		if deckDay.Complete {
+			completed[deckDay.Date] = true
 		}
+	}
 
-		decks, err := v.mCli.GetDecklists(latestTime)
+	// without any records of fetched days, the decks were stored before days were recorded
+	// so every day before the latest stored deck is recorded as fetched, otherwise they would be fetched again
+	if len(deckDays) == 0 {
+		deck, err := v.db.GetLatestDeck()
 		if err != nil {
-			// marvelcdb api seems to return a 500 if there are simply no decks, just try the next day
-			if !strings.Contains(err.Error(), "500 Internal Server Error") {
-				return false, err
+			return false, err
+		}
+		if deck != nil {
+			latest := deck.DateCreated()
+			resumeTime := time.Date(latest.Year(), latest.Month(), latest.Day(), 0, 0, 0, 0, time.UTC)
+			for day := startTime; day.Before(resumeTime); day = day.Add(time.Hour * 24) {
+				deckDay := &DeckDay{Date: day.Format("2006-01-02"), Complete: true}
+				if err := v.db.SaveDeckDay(deckDay); err != nil {
+					return false, err
+				}
+				completed[deckDay.Date] = true
 			}
 		}
-		log.Printf("Received %v decks for %v\n", len(decks), latestTime.Format("2006-01-02"))
+	}
 
-		if len(decks) > 0 {
-			oldCount := v.db.GetCollectionSize(cDecks)
-			if err := mw.CreateMany(v.db, cDecks, decks); err != nil {
-				return false, err
-			}
-			newCount := v.db.GetCollectionSize(cDecks)
-			log.Printf("Added %v new decks\n", newCount-oldCount)
-			if oldCount != newCount {
-				isNewDecks = true
-			}
+	// build the list of days that still need to be fetched
+	now := time.Now()
+	total := 0
+	days := []time.Time{}
+	for day := startTime; !day.After(now); day = day.Add(time.Hour * 24) {
+		total++
+		if !completed[day.Format("2006-01-02")] {
+			days = append(days, day)
 		}
+	}
 
-		latestTime = latestTime.Add(time.Hour * 24)
+	v.progressMutex.Lock()
+	v.progress.DaysTotal = total
+	v.progress.DaysDone = total - len(days)
+	v.progressMutex.Unlock()
+
+	// fetch the remaining days with a bounded pool of workers
+	oldCount := v.db.CountDecks()
+	g, ctx := errgroup.WithContext(context.Background())
+	g.SetLimit(v.deckWorkers)
+	for _, day := range days {
+		if ctx.Err() != nil {
+			break
+		}
+		day := day
+		g.Go(func() error {
+			return v.updateDeckDay(day)
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return false, err
 	}
+	newCount := v.db.CountDecks()
+	log.Printf("Added %v new decks\n", newCount-oldCount)
+
+	return oldCount != newCount, nil
+}
 
-	// defer log.Println("Local deck count:", mw.GetCollectionSize(v.db, cDecks))
+// updateDeckDay fetches and stores all decks of a single day and records that the day was fetched
+func (v *Valuator) updateDeckDay(day time.Time) error {
+	fetchedAt := time.Now()
+	decks, err := v.mCli.GetDecklists(day)
+	if err != nil && !errors.Is(err, marvel.ErrNoDecklists) {
+		return err
+	}
+	log.Printf("Received %v decks for %v\n", len(decks), day.Format("2006-01-02"))
 
-	return isNewDecks, nil
+	if len(decks) > 0 {
+		if err := v.db.AddDecks(decks); err != nil {
+			return err
+		}
+	}
+
+	// decks can still be posted until the day is over everywhere, so give it an extra day
+	deckDay := &DeckDay{
+		Date:      day.Format("2006-01-02"),
+		DeckCount: len(decks),
+		Complete:  fetchedAt.After(day.Add(time.Hour * 48)),
+	}
+	if err := v.db.SaveDeckDay(deckDay); err != nil {
+		return err
+	}
+
+	v.progressMutex.Lock()
+	v.progress.DaysDone++
+	v.progressMutex.Unlock()
+
+	return nil
 }
 
 func (v *Valuator) updateCardValues() error {
 	log.Println("Updating local list of base card values.")
 
 	// get all decks
-	allDecks, err := mw.GetAll[marvel.Decklist](v.db, cDecks)
+	allDecks, err := v.db.GetDecks()
 	if err != nil {
 		return err
 	}
 
 	// get all heroes
-	allHeroes, err := mw.GetAll[Hero](v.db, cHeroes)
+	allHeroes, err := v.db.GetHeroes()
 	if err != nil {
 		return err
 	}
@@ -455,13 +876,14 @@ func (v *Valuator) updateCardValues() error {
 	// prepare hero map
 	heroesByCode := map[string]*Hero{}
 	for _, hero := range allHeroes {
-		heroesByCode[hero.Code[:len(hero.Code)-1]] = hero
+		heroesByCode[heroKey(hero.Code)] = hero
 	}
 
 	// get all cards
 	allCards := v.getUniqueCards()
 
 	// loop through every card and check if each deck is eligible to run that card or not and if it does
+	now := time.Now()
 	cardValues := []*CardValue{}
 	for _, card := range allCards {
 		cardValue := &CardValue{
@@ -471,47 +893,29 @@ func (v *Valuator) updateCardValues() error {
 			WeightMod: 1,
 		}
 
-		for _, deck := range allDecks {
-			hero := heroesByCode[deck.HeroCode[:len(deck.HeroCode)-1]]
-			if hero == nil {
-				return fmt.Errorf("could not find hero from decklist")
-			}
-
-			if isCardEligibleForDeck(card, deck, hero) {
-				cardValue.EligibleDecksCount += 1
-
-				// check if card (or duplicates) are in the deck
-				toCheck := []string{card.Code}
-				toCheck = append(toCheck, card.DuplicateBy...)
-				inUse := false
-				for _, code := range toCheck {
-					if count, ok := deck.Slots[code]; ok && count > 0 {
-						inUse = true
-						break
-					}
-				}
-				if inUse {
-					cardValue.InDecksCount += 1
-				}
-			}
+		if err := countDecks(cardValue, allDecks, heroesByCode); err != nil {
+			return err
 		}
 
+		cardValue.CalculateDeckWeights(v.halfLife, now)
+
+		cardValue.CalculateTypicalCopies()
+		cardValue.AddedCopies = cardValue.TypicalCopies
+		cardValue.CalculateNewMod()
 		cardValue.Calculate()
 		cardValues = append(cardValues, cardValue)
 	}
 
 	sort.Slice(cardValues, func(i, j int) bool { return cardValues[i].Value > cardValues[j].Value })
 
-	// defer log.Println("Local card values count:", mw.GetCollectionSize(v.db, cCardValues))
-
-	return mw.ReplaceManyID(v.db, cCardValues, cardValues)
+	return v.db.SaveCardValues(cardValues)
 }
 
 func (v *Valuator) updatePackValues() error {
 	log.Println("Updating local list of base pack values.")
 
 	// get packs
-	allPacks, err := mw.GetAll[marvel.Pack](v.db, cPacks)
+	allPacks, err := v.db.GetPacks()
 	if err != nil {
 		return err
 	}
@@ -534,11 +938,15 @@ func (v *Valuator) updatePackValues() error {
 		for i, pCard := range packCards {
 			cardCodes[i] = pCard.Code
 		}
-		filter := bson.D{{Key: "_id", Value: bson.D{{"$in", cardCodes}}}}
-		cvs, err := mw.GetMany[CardValue](v.db, cCardValues, filter, bson.M{"Value": -1})
+		cvs, err := v.db.GetCardValuesByCodes(cardCodes)
 		if err != nil {
 			return err
 		}
+		for _, cv := range cvs {
+			cv.AddedCopies = cv.Card.CopiesIn(pack.Code)
+			cv.CalculateNewMod()
+			cv.Calculate()
+		}
 
 		packValue := &PackValue{
 			Code:       pack.Code,
@@ -551,36 +959,74 @@ func (v *Valuator) updatePackValues() error {
 
 	sort.Slice(packValues, func(i, j int) bool { return packValues[i].ValueSum > packValues[j].ValueSum })
 
-	// defer log.Println("Local pack values count:", mw.GetCollectionSize(v.db, cPackValues))
+	return v.db.SavePackValues(packValues)
+}
 
-	return mw.ReplaceManyID(v.db, cPackValues, packValues)
+// validateOwned normalises the owned pack codes to the stored packs' codes
+// an UnknownPacksError is returned if any of them don't match a stored pack
+func (v *Valuator) validateOwned(owned []string) ([]string, error) {
+	packs, err := v.db.GetPacks()
+	if err != nil {
+		return nil, err
+	}
+	if len(packs) == 0 && len(owned) > 0 {
+		// nothing to check against yet, so make sure the first update has started
+		if err := v.updateIfNeeded(); err != nil {
+			return nil, err
+		}
+		return nil, ErrInitialising
+	}
+
+	known, unknown := matchPackCodes(owned, packs)
+	if len(unknown) > 0 {
+		return nil, &UnknownPacksError{Codes: unknown}
+	}
+	return known, nil
 }
 
-func (v *Valuator) getCardsFromPack(packCode string) ([]*Card, error) {
-	filter := bson.D{
-		{"$and",
-			bson.A{
-				bson.D{{"packcodes", packCode}},
-				bson.D{{"aspect", bson.D{{"$in", []string{"basic", "justice", "protection", "aggression", "leadership"}}}}},
-			},
-		},
+// matchPackCodes matches pack codes to the packs, ignoring case, whitespace and empty codes
+// returns the codes as the packs spell them and the sorted codes that didn't match a pack
+func matchPackCodes(codes []string, packs []*marvel.Pack) (known []string, unknown []string) {
+	packCodes := map[string]string{}
+	for _, pack := range packs {
+		packCodes[strings.ToLower(pack.Code)] = pack.Code
+	}
+
+	known, unknown = []string{}, []string{}
+	for _, code := range codes {
+		code = strings.TrimSpace(code)
+		if code == "" {
+			continue
+		}
+		if packCode, ok := packCodes[strings.ToLower(code)]; ok {
+			known = append(known, packCode)
+		} else if !utils.StringsContains(unknown, code) {
+			unknown = append(unknown, code)
+		}
 	}
+	sort.Strings(unknown)
 
-	return mw.GetMany[Card](v.db, cCards, filter, mw.BsonNoneM)
+	return known, unknown
 }
 
-func (v *Valuator) getCardsFromPacks(packCodes []string) (map[string]*Card, error) {
-	allCards := map[string]*Card{}
+func (v *Valuator) getCardsFromPack(packCode string) ([]*Card, error) {
+	return v.db.GetCardsFromPack(packCode, []string{"basic", "justice", "protection", "aggression", "leadership"})
+}
+
+// getOwnedCopies returns how many copies of each card are in the given packs
+// a pack code can be repeated to count more than one copy of that pack
+func (v *Valuator) getOwnedCopies(packCodes []string) (map[string]int, error) {
+	ownedCopies := map[string]int{}
 	for _, packCode := range packCodes {
 		cards, err := v.getCardsFromPack(packCode)
 		if err != nil {
 			return nil, err
 		}
 		for _, card := range cards {
-			allCards[card.Code] = card
+			ownedCopies[card.Code] += card.CopiesIn(packCode)
 		}
 	}
-	return allCards, nil
+	return ownedCopies, nil
 }
 
 func (v *Valuator) getUniqueCards() []*Card {
@@ -593,48 +1039,122 @@ func (v *Valuator) getUniqueCards() []*Card {
 	return cards
 }
 
-func isCardEligibleForDeck(card *Card, deck *marvel.Decklist, hero *Hero) bool {
+// countDecks sets the deck counts of the card value to the decks that are eligible to run the card
+// and the decks that do
+func countDecks(cv *CardValue, decks []*marvel.Decklist, heroesByCode map[string]*Hero) error {
+	cv.EligibleDecksCount = 0
+	cv.InDecksCount = 0
+	cv.InDecksCopies = 0
+	cv.EligibleDecksByMonth = map[string]int{}
+	cv.InDecksByMonth = map[string]int{}
+	cv.ExcludedDecks = map[ExclusionReason]int{}
+
+	for _, deck := range decks {
+		hero := heroesByCode[heroKey(deck.HeroCode)]
+		if hero == nil {
+			return fmt.Errorf("could not find hero from decklist")
+		}
+
+		eligible, reason := isCardEligibleForDeck(cv.Card, deck, hero)
+		if !eligible {
+			cv.ExcludedDecks[reason] += 1
+			continue
+		}
+
+		month := deck.DateUpdated().Format("2006-01")
+		cv.EligibleDecksCount += 1
+		cv.EligibleDecksByMonth[month] += 1
+
+		// check if card (or duplicates) are in the deck and how many copies
+		toCheck := []string{cv.Card.Code}
+		toCheck = append(toCheck, cv.Card.DuplicateBy...)
+		copies := 0
+		for _, code := range toCheck {
+			if count, ok := deck.Slots[code]; ok && count > 0 {
+				copies += count
+			}
+		}
+		if copies > 0 {
+			cv.InDecksCount += 1
+			cv.InDecksByMonth[month] += 1
+			cv.InDecksCopies += copies
+		}
+	}
+
+	return nil
+}
+
+// heroKey returns the hero code without its side (a/b), which is how decks and heroes are matched
+func heroKey(code string) string {
+	return strings.TrimRight(strings.ToLower(code), "abcdefghijklmnopqrstuvwxyz")
+}
+
+// isCardEligibleForDeck checks whether the deck could have run the card
+// if not, the reason is returned as well
+func isCardEligibleForDeck(card *Card, deck *marvel.Decklist, hero *Hero) (bool, ExclusionReason) {
 	// not eligible if the deck was made before the card released
 	if deck.DateUpdated().Before(card.DateAvailable) {
-		return false
+		return false, ReasonReleasedAfterDeck
 	}
 
 	// card aspect needs to be basic or match the deck
 	if card.Aspect != "basic" && !utils.StringsContains(deck.Aspects(), card.Aspect) {
-		return false
+		return false, ReasonAspectMismatch
 	}
 
+	// what hero is the deck running
+	// can that hero play the card
+	reason := heroLockReason(card, hero)
+	return reason == "", reason
+}
+
+// canHeroPlayCard checks the card's name and trait locks against the hero
+func canHeroPlayCard(card *Card, hero *Hero) bool {
+	return heroLockReason(card, hero) == ""
+}
+
+// heroLockReason returns which of the card's locks the hero doesn't meet, or "" if they can play it
+func heroLockReason(card *Card, hero *Hero) ExclusionReason {
 	// does the card name match the heroes name
-	// TODO
+	if len(card.LockingNames) > 0 && !utils.StringsContains(card.LockingNames, hero.Name) {
+		return ReasonNameLockUnmet
+	}
 
 	// does the card have a locking trait
-	// what hero is the deck running
 	// does that hero have the locking trait
 	if len(card.LockingTraits) > 0 {
 		for _, trait := range card.LockingTraits {
 			if utils.StringsContains(hero.Traits, trait) {
-				return true
+				return ""
 			}
 		}
-		return false
+		return ReasonTraitLockUnmet
 	}
 
-	return true
+	return ""
 }
 
-func adjustCardValue(cv *CardValue, ownedCards map[string]*Card, ownedHeroes map[string]*Hero, allHeroes []*Hero, packCode string, aspectWeights map[string]float64) {
-	// owned cards
-	if _, ok := ownedCards[cv.Code]; ok {
-		cv.NewMod = 0
+func adjustCardValue(cv *CardValue, ownedCopies map[string]int, ownedHeroes map[string]*Hero, allHeroes []*Hero, packCode string, opts ValuationOptions) {
+	// owned copies and the copies this pack adds
+	// without a pack, the card is valued as if a full playset was added
+	cv.OwnedCopies = ownedCopies[cv.Code]
+	if packCode != "" {
+		cv.AddedCopies = cv.Card.CopiesIn(packCode)
+	} else {
+		cv.AddedCopies = cv.TypicalCopies
 	}
+	cv.CalculateNewMod()
+
+	// aspect, type, trait and card weights
+	cv.WeightMod = opts.Weights.WeightFor(cv.Card)
 
-	// aspect weight
-	if weight, ok := aspectWeights[cv.Card.Aspect]; ok {
-		cv.WeightMod = weight
+	// recency of the decks counted towards popularity
+	if opts.PopularityHalfLife != nil {
+		cv.CalculateDeckWeights(*opts.PopularityHalfLife, time.Now())
 	}
 
-	// trait-locked cards
-	if cv.Card.LockingTraits != nil && len(cv.Card.LockingTraits) > 0 {
+	// trait-locked and name-locked cards
+	if len(cv.Card.LockingTraits) > 0 || len(cv.Card.LockingNames) > 0 {
 		// heroes in this pack
 		packHeroes := map[string]*Hero{}
 		for _, hero := range allHeroes {
@@ -654,14 +1174,11 @@ func adjustCardValue(cv *CardValue, ownedCards map[string]*Card, ownedHeroes map
 
 		cv.EligibleHeroCount = len(futureOwned)
 
-		// how many of your heroes have the trait?
+		// how many of your heroes can play the card?
 		cv.OwnedHeroCount = 0
 		for _, hero := range futureOwned {
-			for _, trait := range cv.Card.LockingTraits {
-				if utils.StringsContains(hero.Traits, trait) {
-					cv.OwnedHeroCount++
-					break
-				}
+			if canHeroPlayCard(cv.Card, hero) {
+				cv.OwnedHeroCount++
 			}
 		}
 	}
@@ -712,3 +1229,23 @@ func parseLockingTraits(text string) []string {
 
 	return []string{}
 }
+
+func parseLockingNames(text string) []string {
+	// "Play only if your identity is BLANK" or "Play only if your identity is BLANK or BLANK"
+	// the name ends at the first period, unless it is part of a title like "Ms." or "Dr."
+	r := regexp.MustCompile(`Play only if your identity is ((?:\b(?:Mrs?|Ms|Dr|St)\.|[^.])+)\.`)
+	matches := r.FindStringSubmatch(text)
+	if len(matches) < 2 {
+		return []string{}
+	}
+
+	names := []string{}
+	for _, name := range strings.Split(matches[1], " or ") {
+		name = regexp.MustCompile(`<[^>]*>`).ReplaceAllString(name, "")
+		name = strings.Trim(strings.TrimSpace(name), "[]")
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
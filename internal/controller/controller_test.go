@@ -0,0 +1,230 @@
+package controller
+
+import (
+	"errors"
+	"io"
+	"log"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	marvel "github.com/colbymilton/marchamps-valuator/internal/marvelcdb"
+)
+
+// newTestValuator returns a Valuator that has been updated from the recorded responses in testdata
+func newTestValuator(t *testing.T) *Valuator {
+	t.Helper()
+
+	// the replay client logs every day it is asked for
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	rcli, err := marvel.NewReplayClient("testdata/marvelcdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := NewValuatorWith(NewMemoryStorage(), rcli)
+	v.decksFrom = time.Date(2024, 1, 9, 0, 0, 0, 0, time.UTC)
+	if err := v.updateAll(); err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestParseLockingNames(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{"no lock", "Deal 3 damage to an enemy.", []string{}},
+		{"single name", "Play only if your identity is Spider-Man.", []string{"Spider-Man"}},
+		{"two names", "Play only if your identity is Spider-Man or Spider-Woman.", []string{"Spider-Man", "Spider-Woman"}},
+		{"name with a period", "Play only if your identity is Ms. Marvel.", []string{"Ms. Marvel"}},
+		{"title with a period", "Play only if your identity is Dr. Strange. Draw a card.", []string{"Dr. Strange"}},
+		{"bold name", "Play only if your identity is <b>Ms. Marvel</b>.\nDraw 1 card.", []string{"Ms. Marvel"}},
+		{"bracketed names", "Play only if your identity is [Captain America] or [Mr. Fantastic].", []string{"Captain America", "Mr. Fantastic"}},
+		{"later sentence", "Hero Action: Exhaust. Play only if your identity is Hulk.", []string{"Hulk"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseLockingNames(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseLockingNames(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchPackCodes(t *testing.T) {
+	packs := []*marvel.Pack{{Code: "core"}, {Code: "hulk"}, {Code: "ronan"}}
+	tests := []struct {
+		name        string
+		codes       []string
+		wantKnown   []string
+		wantUnknown []string
+	}{
+		{"nothing owned", nil, []string{}, []string{}},
+		{"known codes", []string{"core", "hulk"}, []string{"core", "hulk"}, []string{}},
+		{"case and whitespace", []string{" Core ", "HULK"}, []string{"core", "hulk"}, []string{}},
+		{"empty codes", []string{"", " ", "core"}, []string{"core"}, []string{}},
+		{"repeated packs", []string{"core", "core"}, []string{"core", "core"}, []string{}},
+		{"unknown codes", []string{"zed", "core", "bogus", "zed"}, []string{"core"}, []string{"bogus", "zed"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			known, unknown := matchPackCodes(tt.codes, packs)
+			if !reflect.DeepEqual(known, tt.wantKnown) || !reflect.DeepEqual(unknown, tt.wantUnknown) {
+				t.Errorf("matchPackCodes(%q) = %q, %q, want %q, %q", tt.codes, known, unknown, tt.wantKnown, tt.wantUnknown)
+			}
+		})
+	}
+}
+
+func TestValueAllPacks(t *testing.T) {
+	v := newTestValuator(t)
+
+	// values keyed by pack and card code
+	values := func(owned []string) map[string]int {
+		t.Helper()
+		pvs, err := v.ValueAllPacks(owned, ValuationOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		byCode := map[string]int{}
+		for _, pv := range pvs {
+			for _, cv := range pv.CardValues {
+				byCode[pv.Code+"/"+cv.Card.Code] = cv.Value
+			}
+		}
+		return byCode
+	}
+
+	none := values(nil)
+	withCore := values([]string{"core"})
+	tests := []struct {
+		key      string
+		none     int
+		withCore int
+	}{
+		{"core/01050", 200, 0},
+		{"core/01060", 200, 0},
+		// the core set's two copies of tackle are all a deck needs
+		{"hulk/01060", 100, 0},
+		// the ally is locked to genius heroes, and the only one is in the core set
+		{"hulk/02020", 0, 100},
+	}
+	for _, tt := range tests {
+		if none[tt.key] != tt.none || withCore[tt.key] != tt.withCore {
+			t.Errorf("%v value = %v, %v when owning core, want %v, %v", tt.key, none[tt.key], withCore[tt.key], tt.none, tt.withCore)
+		}
+	}
+
+}
+
+func TestValueAllPacksValidatesOwned(t *testing.T) {
+	v := newTestValuator(t)
+
+	pvs, err := v.ValueAllPacks([]string{" Core "}, ValuationOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, pv := range pvs {
+		if pv.Code == "core" && pv.ValueSum != 0 {
+			t.Errorf("core value when owned as %q = %v, want 0", " Core ", pv.ValueSum)
+		}
+	}
+
+	_, err = v.ValueAllPacks([]string{"core", "bogus"}, ValuationOptions{})
+	var unknownPacks *UnknownPacksError
+	if !errors.As(err, &unknownPacks) || !reflect.DeepEqual(unknownPacks.Codes, []string{"bogus"}) {
+		t.Errorf("ValueAllPacks with an unknown pack: err = %v, want unknown pack codes [bogus]", err)
+	}
+}
+
+func TestUpdateDecksResumesFromStoredDecks(t *testing.T) {
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	rcli, err := marvel.NewReplayClient("testdata/marvelcdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := NewMemoryStorage()
+	v := NewValuatorWith(db, rcli)
+	v.decksFrom = time.Date(2024, 1, 5, 0, 0, 0, 0, time.UTC)
+
+	// decks stored by a version that didn't record the days it fetched
+	if err := db.AddDecks([]*marvel.Decklist{{Id: 1, DateCreatedStr: "2024-01-08T10:00:00+00:00"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := v.updateDecks(); err != nil {
+		t.Fatal(err)
+	}
+
+	deckDays, err := db.GetDeckDays()
+	if err != nil {
+		t.Fatal(err)
+	}
+	complete := map[string]bool{}
+	for _, deckDay := range deckDays {
+		complete[deckDay.Date] = deckDay.Complete
+	}
+	for _, date := range []string{"2024-01-05", "2024-01-06", "2024-01-07", "2024-01-08", "2024-01-10"} {
+		if !complete[date] {
+			t.Errorf("day %v isn't recorded as fetched", date)
+		}
+	}
+	if today := time.Now().UTC().Format("2006-01-02"); complete[today] {
+		t.Errorf("today (%v) is recorded as complete", today)
+	}
+}
+
+func TestUpdateDecksNeedsAStartDate(t *testing.T) {
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	rcli, err := marvel.NewReplayClient("testdata/marvelcdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := NewMemoryStorage()
+	v := NewValuatorWith(db, rcli)
+	if err := db.AddDecks([]*marvel.Decklist{{Id: 1, DateCreatedStr: "2024-01-08T10:00:00+00:00"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := v.updateDecks(); err == nil {
+		t.Error("updateDecks() succeeded without a start date")
+	}
+	if deckDays, _ := db.GetDeckDays(); len(deckDays) != 0 {
+		t.Errorf("updateDecks() recorded %v days without a start date, want 0", len(deckDays))
+	}
+}
+
+func TestExplainCardValueListsRecentDecks(t *testing.T) {
+	v := newTestValuator(t)
+
+	// more aggression decks than an explanation lists, one a minute after the fixture's deck
+	decks := []*marvel.Decklist{}
+	for i := 1; i <= cMaxExplainedDecks+10; i++ {
+		date := time.Date(2024, 1, 10, 12, i, 0, 0, time.UTC).Format(time.RFC3339)
+		decks = append(decks, &marvel.Decklist{Id: 100 + i, DateCreatedStr: date, DateUpdatedStr: date, Meta: `{"aspect":"aggression"}`, HeroCode: "01001a"})
+	}
+	if err := v.db.AddDecks(decks); err != nil {
+		t.Fatal(err)
+	}
+
+	exp, err := v.ExplainCardValue("01060", nil, ValuationOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp.EligibleDecksTotal != cMaxExplainedDecks+11 {
+		t.Errorf("EligibleDecksTotal = %v, want %v", exp.EligibleDecksTotal, cMaxExplainedDecks+11)
+	}
+	if len(exp.EligibleDecks) != cMaxExplainedDecks || exp.EligibleDecks[0].Id != 100+cMaxExplainedDecks+10 {
+		t.Errorf("listed %v decks starting with %v, want %v starting with the latest", len(exp.EligibleDecks), exp.EligibleDecks[0].Id, cMaxExplainedDecks)
+	}
+}
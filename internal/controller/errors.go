@@ -0,0 +1,81 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	marvel "github.com/colbymilton/marchamps-valuator/internal/marvelcdb"
+)
+
+// ErrorKind describes what went wrong, so that the API can respond with a matching status code
+type ErrorKind string
+
+const (
+	KindInternal            ErrorKind = "internal"
+	KindValidation          ErrorKind = "validation"
+	KindNotFound            ErrorKind = "not-found"
+	KindUpstreamUnavailable ErrorKind = "upstream-unavailable"
+	KindInitialising        ErrorKind = "initialising"
+	// KindNotConfigured is a feature that the server hasn't been set up for, retrying won't help
+	KindNotConfigured ErrorKind = "not-configured"
+)
+
+// Error is an error of a known kind with a machine-readable code, like "unknown-hero"
+type Error struct {
+	Kind ErrorKind
+	Code string
+	Err  error
+}
+
+func NewError(kind ErrorKind, code string, err error) *Error {
+	return &Error{Kind: kind, Code: code, Err: err}
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func validationError(code, format string, args ...any) error {
+	return NewError(KindValidation, code, fmt.Errorf(format, args...))
+}
+
+func notFoundError(code, format string, args ...any) error {
+	return NewError(KindNotFound, code, fmt.Errorf(format, args...))
+}
+
+// ErrInitialising is returned while the first-time setup is still fetching data
+var ErrInitialising = NewError(KindInitialising, "initialising", errors.New("the valuator is still initialising"))
+
+// ErrNoPrices is returned by price based endpoints when no pack prices are configured
+var ErrNoPrices = NewError(KindNotConfigured, "no-prices", errors.New("no pack prices are configured (PACK_PRICES_FILE)"))
+
+// UnknownPacksError is returned when owned pack codes don't match any stored pack
+type UnknownPacksError struct {
+	Codes []string
+}
+
+func (e *UnknownPacksError) Error() string {
+	return "unknown pack codes: " + strings.Join(e.Codes, ", ")
+}
+
+// Classify returns the kind and code of an error
+// errors that marvelcdb caused are upstream-unavailable, anything else unknown is internal
+func Classify(err error) (ErrorKind, string) {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Kind, e.Code
+	}
+	var unknownPacks *UnknownPacksError
+	if errors.As(err, &unknownPacks) {
+		return KindValidation, "unknown-packs"
+	}
+	if marvel.IsUpstreamError(err) {
+		return KindUpstreamUnavailable, "marvelcdb-unavailable"
+	}
+	return KindInternal, "internal"
+}
@@ -0,0 +1,197 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	marvel "github.com/colbymilton/marchamps-valuator/internal/marvelcdb"
+	"github.com/colbymilton/marchamps-valuator/internal/utils"
+)
+
+const (
+	// cMaxImportCardCopies is the most copies of a card that an import can list, a full playset
+	cMaxImportCardCopies = 3
+	// cMaxImportPackCopies is the most copies of a pack that an import can list
+	cMaxImportPackCopies = 5
+)
+
+// ImportRequest is an owned collection in one of the formats MarvelCDB exports:
+// a list of pack codes, card code quantities, or a deck list
+type ImportRequest struct {
+	Packs PackList       `json:"packs"`
+	Cards map[string]int `json:"cards"`
+	// Slots and HeroCode are the card quantities and identity of a deck list
+	Slots    map[string]int `json:"slots"`
+	HeroCode string         `json:"investigator_code"`
+}
+
+// PackList is a list of pack codes, which can also be given as pack code quantities
+// a pack code appears once for every copy of the pack
+type PackList []string
+
+func (pl *PackList) UnmarshalJSON(data []byte) error {
+	codes := []string{}
+	if err := json.Unmarshal(data, &codes); err == nil {
+		*pl = codes
+		return nil
+	}
+
+	quantities := map[string]int{}
+	if err := json.Unmarshal(data, &quantities); err != nil {
+		return fmt.Errorf("packs must be a list of pack codes or pack code quantities")
+	}
+	for code, quantity := range quantities {
+		if quantity < 0 || quantity > cMaxImportPackCopies {
+			return fmt.Errorf("pack %v has %v copies, an import can list 0 to %v", code, quantity, cMaxImportPackCopies)
+		}
+		for i := 0; i < quantity; i++ {
+			codes = append(codes, code)
+		}
+	}
+	sort.Strings(codes)
+	*pl = codes
+	return nil
+}
+
+// ParseImport reads an import from JSON, which is either an ImportRequest or a list of pack codes
+func ParseImport(data []byte) (*ImportRequest, error) {
+	req := &ImportRequest{}
+	packs := []string{}
+	if err := json.Unmarshal(data, &packs); err == nil {
+		req.Packs = packs
+		return req, nil
+	}
+
+	if err := json.Unmarshal(data, req); err != nil {
+		return nil, NewError(KindValidation, "invalid-import", fmt.Errorf("could not read import: %w", err))
+	}
+	return req, nil
+}
+
+// validate checks that the import doesn't list more copies of a card or pack than it can
+func (req *ImportRequest) validate() error {
+	packCopies := map[string]int{}
+	for _, code := range req.Packs {
+		packCopies[strings.ToLower(strings.TrimSpace(code))]++
+	}
+	for code, copies := range packCopies {
+		if copies > cMaxImportPackCopies {
+			return validationError("invalid-import", "pack %v has %v copies, an import can list 0 to %v", code, copies, cMaxImportPackCopies)
+		}
+	}
+
+	for _, quantities := range []map[string]int{req.Cards, req.Slots} {
+		for code, quantity := range quantities {
+			if quantity < 0 || quantity > cMaxImportCardCopies {
+				return validationError("invalid-import", "card %v has %v copies, an import can list 0 to %v", code, quantity, cMaxImportCardCopies)
+			}
+		}
+	}
+	return nil
+}
+
+// ImportCollection handles the /import endpoint
+// it converts the import into owned pack codes, adding the packs needed for any cards it lists
+func (v *Valuator) ImportCollection(req *ImportRequest) (*ImportResult, error) {
+	if err := req.validate(); err != nil {
+		return nil, err
+	}
+	if err := v.updateIfNeeded(); err != nil {
+		return nil, err
+	}
+
+	packs, err := v.db.GetPacks()
+	if err != nil {
+		return nil, err
+	}
+	cards, err := v.db.GetCards()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ImportResult{UnknownCards: []string{}}
+	result.Owned, result.UnknownPacks = matchPackCodes(req.Packs, packs)
+
+	// duplicates count as copies of the card they duplicate
+	cardsByCode := map[string]*Card{}
+	for _, card := range cards {
+		cardsByCode[strings.ToLower(card.Code)] = card
+		for _, dup := range card.DuplicateBy {
+			cardsByCode[strings.ToLower(dup)] = card
+		}
+	}
+	wanted := map[string]int{}
+	addCards := func(quantities map[string]int) {
+		for code, quantity := range quantities {
+			card, ok := cardsByCode[strings.ToLower(strings.TrimSpace(code))]
+			if !ok {
+				if !utils.StringsContains(result.UnknownCards, code) {
+					result.UnknownCards = append(result.UnknownCards, code)
+				}
+				continue
+			}
+			wanted[card.Code] += quantity
+		}
+	}
+	addCards(req.Cards)
+	addCards(req.Slots)
+	if req.HeroCode != "" {
+		addCards(map[string]int{req.HeroCode: 1})
+	}
+
+	result.Owned = append(result.Owned, packsForCards(wanted, cardsByCode, packs, result.Owned)...)
+
+	sort.Strings(result.UnknownCards)
+
+	return result, nil
+}
+
+// packsForCards returns the packs to add to owned so that every wanted card has enough copies
+// it keeps picking the pack that provides the most missing copies, preferring older packs
+func packsForCards(wanted map[string]int, cardsByCode map[string]*Card, packs []*marvel.Pack, owned []string) []string {
+	missing := map[string]int{}
+	for code, quantity := range wanted {
+		card := cardsByCode[strings.ToLower(code)]
+		have := 0
+		for _, packCode := range owned {
+			have += card.CopiesIn(packCode)
+		}
+		if quantity > have {
+			missing[code] = quantity - have
+		}
+	}
+
+	added := []string{}
+	for len(missing) > 0 {
+		// packs are sorted by release date, so the oldest pack wins a tie
+		var best string
+		bestCopies := 0
+		for _, pack := range packs {
+			copies := 0
+			for code, count := range missing {
+				copies += int(math.Min(float64(count), float64(cardsByCode[strings.ToLower(code)].CopiesIn(pack.Code))))
+			}
+			if copies > bestCopies {
+				best, bestCopies = pack.Code, copies
+			}
+		}
+		if best == "" {
+			break // the remaining cards aren't in any stored pack
+		}
+
+		added = append(added, best)
+		for code, count := range missing {
+			count -= cardsByCode[strings.ToLower(code)].CopiesIn(best)
+			if count > 0 {
+				missing[code] = count
+			} else {
+				delete(missing, code)
+			}
+		}
+	}
+
+	return added
+}
@@ -0,0 +1,97 @@
+package controller
+
+import (
+	"reflect"
+	"testing"
+
+	marvel "github.com/colbymilton/marchamps-valuator/internal/marvelcdb"
+)
+
+func TestPacksForCards(t *testing.T) {
+	packs := []*marvel.Pack{{Code: "core"}, {Code: "hulk"}, {Code: "thor"}}
+	tackle := &Card{Code: "tackle", PackCodes: []string{"core", "hulk"}, PackQuantities: map[string]int{"core": 2, "hulk": 1}}
+	helper := &Card{Code: "helper", PackCodes: []string{"hulk"}, PackQuantities: map[string]int{"hulk": 1}}
+	hammer := &Card{Code: "hammer", PackCodes: []string{"thor"}, PackQuantities: map[string]int{"thor": 1}}
+	cardsByCode := map[string]*Card{"tackle": tackle, "helper": helper, "hammer": hammer}
+
+	tests := []struct {
+		name   string
+		wanted map[string]int
+		owned  []string
+		want   []string
+	}{
+		{"nothing wanted", map[string]int{}, nil, []string{}},
+		{"already owned", map[string]int{"tackle": 2}, []string{"core"}, []string{}},
+		{"most copies first", map[string]int{"tackle": 2}, nil, []string{"core"}},
+		{"pack covering more cards wins", map[string]int{"tackle": 1, "helper": 1}, nil, []string{"hulk"}},
+		{"older pack wins a tie", map[string]int{"tackle": 1}, nil, []string{"core"}},
+		{"extra copies of a pack", map[string]int{"hammer": 2}, nil, []string{"thor", "thor"}},
+		{"topped up from owned", map[string]int{"tackle": 3}, []string{"core"}, []string{"core"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := packsForCards(tt.wanted, cardsByCode, packs, tt.owned); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("packsForCards() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestImportCollection(t *testing.T) {
+	v := newTestValuator(t)
+
+	req, err := ParseImport([]byte(`{"slots":{"01060":3,"02020":1,"99999":1},"investigator_code":"01001a","packs":["nope"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := v.ImportCollection(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := &ImportResult{Owned: []string{"core", "hulk"}, UnknownPacks: []string{"nope"}, UnknownCards: []string{"99999"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ImportCollection() = %+v, want %+v", got, want)
+	}
+}
+
+func TestImportRejectsQuantities(t *testing.T) {
+	v := newTestValuator(t)
+
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"too many pack copies", `{"packs":{"core":2000000000}}`},
+		{"negative pack copies", `{"packs":{"core":-1}}`},
+		{"repeated pack codes", `{"packs":["core","core","Core","core","core","core"]}`},
+		{"too many card copies", `{"cards":{"01060":1000000000}}`},
+		{"negative card copies", `{"cards":{"01060":-2}}`},
+		{"too many deck copies", `{"slots":{"01060":4}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := ParseImport([]byte(tt.data))
+			if err == nil {
+				_, err = v.ImportCollection(req)
+			}
+			if kind, code := Classify(err); kind != KindValidation || code != "invalid-import" {
+				t.Errorf("import of %v: err = %v, want an invalid-import validation error", tt.data, err)
+			}
+		})
+	}
+
+	req, err := ParseImport([]byte(`{"packs":{"core":2},"cards":{"01060":3}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := v.ImportCollection(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"core", "core"}; !reflect.DeepEqual(got.Owned, want) {
+		t.Errorf("ImportCollection().Owned = %q, want %q", got.Owned, want)
+	}
+}
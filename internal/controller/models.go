@@ -3,6 +3,7 @@ package controller
 import (
 	"fmt"
 	"math"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -16,40 +17,75 @@ type Meta struct {
 	LastUpdated time.Time
 }
 
+// DeckDay records that the decklists of a single day have been fetched
+type DeckDay struct {
+	Date      string `bson:"_id"`
+	DeckCount int
+	// Complete is false if the day might still get new decks (it hadn't ended when it was fetched)
+	Complete bool
+}
+
+// UpdateProgress describes how far along the current (or most recent) update is
+type UpdateProgress struct {
+	Updating    bool      `json:"updating"`
+	Stage       string    `json:"stage"`
+	DaysTotal   int       `json:"daysTotal"`
+	DaysDone    int       `json:"daysDone"`
+	LastUpdated time.Time `json:"lastUpdated"`
+	LastError   string    `json:"lastError,omitempty"`
+
+	// err is the error of the most recent update
+	err error
+}
+
 type Card struct {
-	Code           string    `json:"code" bson:"_id"`
-	Name           string    `json:"name"`
-	Subname        string    `json:"subname"`
-	PackCodes      []string  `json:"packCodes"`
-	TypeCode       string    `json:"typeCode"`
-	Aspect         string    `json:"aspect"`
-	Traits         []string  `json:"traits"`
-	LockingTraits  []string  `json:"lockingTraits"`
-	DateAvailable  time.Time `json:"dateAvailable"`
-	DuplicateBy    []string  `json:"duplicatedBy"`
-	Text           string    `json:"text"`
-	CardSetName    string    `json:"cardSetName"`
-	LinkedCardCode string    `json:"linkedCard"`
-	ImageSrc       string    `json:"imageSource"`
+	Code           string         `json:"code" bson:"_id"`
+	Name           string         `json:"name"`
+	Subname        string         `json:"subname"`
+	PackCodes      []string       `json:"packCodes"`
+	PackQuantities map[string]int `json:"packQuantities"`
+	DeckLimit      int            `json:"deckLimit"`
+	TypeCode       string         `json:"typeCode"`
+	Aspect         string         `json:"aspect"`
+	Traits         []string       `json:"traits"`
+	LockingTraits  []string       `json:"lockingTraits"`
+	LockingNames   []string       `json:"lockingNames"`
+	DateAvailable  time.Time      `json:"dateAvailable"`
+	DuplicateBy    []string       `json:"duplicatedBy"`
+	Text           string         `json:"text"`
+	CardSetName    string         `json:"cardSetName"`
+	LinkedCardCode string         `json:"linkedCard"`
+	ImageSrc       string         `json:"imageSource"`
 }
 
 type CardValue struct {
-	Code               string  `json:"code" bson:"_id"`
-	Card               *Card   `json:"card"`
-	Value              int     `json:"value"`
-	NewMod             float64 `json:"newMod"`
-	PopularityMod      float64 `json:"popularityMod"`
-	EligibleDecksCount int     `json:"eligibleDecksCount"`
-	InDecksCount       int     `json:"inDecksCount"`
-	TraitMod           float64 `json:"traitMod"`
-	EligibleHeroCount  int     `json:"eligibleHeroCount"`
-	OwnedHeroCount     int     `json:"ownedHeroCount"`
-	WeightMod          float64 `json:"weightMod"`
+	Code                 string                  `json:"code" bson:"_id"`
+	Card                 *Card                   `json:"card"`
+	Value                int                     `json:"value"`
+	NewMod               float64                 `json:"newMod"`
+	PopularityMod        float64                 `json:"popularityMod"`
+	EligibleDecksCount   int                     `json:"eligibleDecksCount"`
+	InDecksCount         int                     `json:"inDecksCount"`
+	InDecksCopies        int                     `json:"inDecksCopies"`
+	EligibleDecksByMonth map[string]int          `json:"-"`
+	InDecksByMonth       map[string]int          `json:"-"`
+	ExcludedDecks        map[ExclusionReason]int `json:"excludedDecks"`
+	EligibleDecksWeight  float64                 `json:"eligibleDecksWeight"`
+	InDecksWeight        float64                 `json:"inDecksWeight"`
+	TypicalCopies        int                     `json:"typicalCopies"`
+	OwnedCopies          int                     `json:"ownedCopies"`
+	AddedCopies          int                     `json:"addedCopies"`
+	TraitMod             float64                 `json:"traitMod"`
+	EligibleHeroCount    int                     `json:"eligibleHeroCount"`
+	OwnedHeroCount       int                     `json:"ownedHeroCount"`
+	WeightMod            float64                 `json:"weightMod"`
 }
 
 func (cv *CardValue) Calculate() {
 	cv.PopularityMod = 1
-	if cv.EligibleDecksCount > 0 {
+	if cv.EligibleDecksWeight > 0 {
+		cv.PopularityMod += cv.InDecksWeight / cv.EligibleDecksWeight
+	} else if cv.EligibleDecksCount > 0 {
 		cv.PopularityMod += float64(cv.InDecksCount) / float64(cv.EligibleDecksCount)
 	}
 	cv.TraitMod = 1
@@ -59,11 +95,125 @@ func (cv *CardValue) Calculate() {
 	cv.Value = int(math.Round(100 * cv.NewMod * cv.PopularityMod * cv.TraitMod * cv.WeightMod))
 }
 
+// CalculateDeckWeights sets EligibleDecksWeight and InDecksWeight from the monthly deck counts,
+// where a deck counts half as much for every halfLife months since it was updated.
+// A halfLife of 0 counts every deck equally.
+func (cv *CardValue) CalculateDeckWeights(halfLife float64, now time.Time) {
+	cv.EligibleDecksWeight = 0
+	cv.InDecksWeight = 0
+	for month, count := range cv.EligibleDecksByMonth {
+		weight := decayWeight(month, halfLife, now)
+		cv.EligibleDecksWeight += weight * float64(count)
+		cv.InDecksWeight += weight * float64(cv.InDecksByMonth[month])
+	}
+}
+
+// decayWeight returns the weight of a deck updated in month ("2006-01")
+func decayWeight(month string, halfLife float64, now time.Time) float64 {
+	if halfLife <= 0 {
+		return 1
+	}
+	t, err := time.Parse("2006-01", month)
+	if err != nil {
+		return 1
+	}
+	ageMonths := math.Max(0, now.Sub(t).Hours()/24/cDaysPerMonth)
+	return math.Pow(0.5, ageMonths/halfLife)
+}
+
+// CalculateTypicalCopies sets TypicalCopies to the average number of copies that decks
+// running the card include, limited by the card's deck limit
+func (cv *CardValue) CalculateTypicalCopies() {
+	limit := cDefaultDeckLimit
+	if cv.Card != nil && cv.Card.DeckLimit > 0 {
+		limit = cv.Card.DeckLimit
+	}
+
+	cv.TypicalCopies = 1
+	if cv.InDecksCount > 0 {
+		cv.TypicalCopies = int(math.Round(float64(cv.InDecksCopies) / float64(cv.InDecksCount)))
+	}
+	cv.TypicalCopies = int(math.Max(1, math.Min(float64(limit), float64(cv.TypicalCopies))))
+}
+
+// CalculateNewMod sets NewMod to the share of a typical playset that the added copies
+// provide on top of the copies that are already owned
+func (cv *CardValue) CalculateNewMod() {
+	needed := cv.TypicalCopies
+	if needed < 1 {
+		needed = 1
+	}
+	have := int(math.Min(float64(cv.OwnedCopies), float64(needed)))
+	after := int(math.Min(float64(cv.OwnedCopies+cv.AddedCopies), float64(needed)))
+	cv.NewMod = float64(after-have) / float64(needed)
+}
+
+// Weights scale card values by the user's preferences
+type Weights struct {
+	Aspects map[string]float64 `json:"aspects"`
+	Types   map[string]float64 `json:"types"`
+	Traits  map[string]float64 `json:"traits"`
+	// Cards overrides every other weight for specific card codes
+	Cards map[string]float64 `json:"cards"`
+}
+
+// WeightFor returns the weight of the card
+// aspect, type and trait weights are multiplied together, unless the card has its own weight
+func (w *Weights) WeightFor(card *Card) float64 {
+	if w == nil {
+		return 1
+	}
+	if weight, ok := w.Cards[card.Code]; ok {
+		return weight
+	}
+
+	weight := 1.0
+	if aw, ok := w.Aspects[card.Aspect]; ok {
+		weight *= aw
+	}
+	if tw, ok := w.Types[card.TypeCode]; ok {
+		weight *= tw
+	}
+	for trait, tw := range w.Traits {
+		for _, cardTrait := range card.Traits {
+			if strings.EqualFold(strings.Trim(cardTrait, "."), trait) {
+				weight *= tw
+				break
+			}
+		}
+	}
+	return weight
+}
+
+// ValuationOptions are the per-request settings used to adjust the base values
+type ValuationOptions struct {
+	Weights *Weights
+	// PopularityHalfLife is the number of months after which a deck counts half as much
+	// towards a card's popularity, 0 counts every deck equally and nil uses the server default
+	PopularityHalfLife *float64
+	// HeroCode restricts the valuation to a single hero: popularity only comes from that hero's decks
+	// and trait-locked cards are only valued against that hero
+	HeroCode string
+}
+
 type PackValue struct {
-	Code       string       `json:"code" bson:"_id"`
-	Pack       *marvel.Pack `json:"pack"`
-	ValueSum   int          `json:"valueSum"`
-	CardValues []*CardValue `json:"cardValues"`
+	Code          string       `json:"code" bson:"_id"`
+	Pack          *marvel.Pack `json:"pack"`
+	ValueSum      int          `json:"valueSum"`
+	Price         float64      `json:"price,omitempty"`
+	ValuePerPrice float64      `json:"valuePerPrice,omitempty"`
+	CardValues    []*CardValue `json:"cardValues"`
+}
+
+// copy returns a copy of the pack value that doesn't share its card values
+func (pv *PackValue) copy() *PackValue {
+	c := *pv
+	c.CardValues = make([]*CardValue, len(pv.CardValues))
+	for i, cv := range pv.CardValues {
+		cvCopy := *cv
+		c.CardValues[i] = &cvCopy
+	}
+	return &c
 }
 
 func (pv *PackValue) Calculate() {
@@ -71,6 +221,154 @@ func (pv *PackValue) Calculate() {
 	for _, cv := range pv.CardValues {
 		pv.ValueSum += cv.Value
 	}
+
+	pv.ValuePerPrice = 0
+	if pv.Price > 0 {
+		pv.ValuePerPrice = float64(pv.ValueSum) / pv.Price
+	}
+}
+
+// PackSort is the order that pack values are returned in
+type PackSort string
+
+const (
+	SortByValue   PackSort = "value"
+	SortByDensity PackSort = "density"
+)
+
+// SortPackValues sorts pack values by their value sum or by their value per price
+// packs without a price come last when sorting by density
+func SortPackValues(pvs []*PackValue, sortBy PackSort) error {
+	switch sortBy {
+	case "", SortByValue:
+		sort.SliceStable(pvs, func(i, j int) bool { return pvs[i].ValueSum > pvs[j].ValueSum })
+	case SortByDensity:
+		sort.SliceStable(pvs, func(i, j int) bool {
+			if (pvs[i].Price > 0) != (pvs[j].Price > 0) {
+				return pvs[i].Price > 0
+			}
+			return pvs[i].ValuePerPrice > pvs[j].ValuePerPrice
+		})
+	default:
+		return validationError("invalid-sort", "unknown sort: %v", sortBy)
+	}
+	return nil
+}
+
+// Collection is a saved set of owned packs and valuation preferences
+type Collection struct {
+	Id    string   `json:"id,omitempty" bson:"_id"`
+	Name  string   `json:"name"`
+	Owned []string `json:"owned"`
+	// Weights, PopularityHalfLife and HeroCode are used like the ValuationOptions of the same names
+	Weights            *Weights  `json:"weights,omitempty"`
+	PopularityHalfLife *float64  `json:"popularityHalfLife,omitempty"`
+	HeroCode           string    `json:"heroCode,omitempty"`
+	ShareCode          string    `json:"shareCode,omitempty"`
+	Created            time.Time `json:"created"`
+	Updated            time.Time `json:"updated"`
+}
+
+// Apply fills in the owned packs and options that a request didn't set from the collection
+func (c *Collection) Apply(owned []string, opts ValuationOptions) ([]string, ValuationOptions) {
+	if len(owned) == 0 {
+		owned = c.Owned
+	}
+	if opts.Weights == nil {
+		opts.Weights = c.Weights
+	}
+	if opts.PopularityHalfLife == nil {
+		opts.PopularityHalfLife = c.PopularityHalfLife
+	}
+	if opts.HeroCode == "" {
+		opts.HeroCode = c.HeroCode
+	}
+	return owned, opts
+}
+
+// ImportResult is the owned input that an import converts to
+type ImportResult struct {
+	Owned        []string `json:"owned"`
+	UnknownPacks []string `json:"unknownPacks"`
+	UnknownCards []string `json:"unknownCards"`
+}
+
+// SharedValuation is what a share code resolves to
+type SharedValuation struct {
+	Collection *Collection  `json:"collection"`
+	PackValues []*PackValue `json:"packValues"`
+}
+
+// CardExplanation is a breakdown of how a card's value was calculated
+type CardExplanation struct {
+	CardValue *CardValue `json:"cardValue"`
+	Breakdown []string   `json:"breakdown"`
+	// MatchedHeroes are the owned heroes that pass the card's trait and name locks
+	MatchedHeroes   []*Hero          `json:"matchedHeroes"`
+	UnmatchedHeroes []*HeroExclusion `json:"unmatchedHeroes"`
+	// EligibleDecks are the most recent of the EligibleDecksTotal decks that could run the card
+	EligibleDecks      []*DeckSummary `json:"eligibleDecks"`
+	EligibleDecksTotal int            `json:"eligibleDecksTotal"`
+}
+
+// HeroExclusion is an owned hero that can't play a card
+type HeroExclusion struct {
+	Hero   *Hero           `json:"hero"`
+	Reason ExclusionReason `json:"reason"`
+}
+
+// ExclusionReason is why a deck couldn't have run a card
+type ExclusionReason string
+
+const (
+	ReasonReleasedAfterDeck ExclusionReason = "released-after-deck"
+	ReasonAspectMismatch    ExclusionReason = "aspect-mismatch"
+	ReasonTraitLockUnmet    ExclusionReason = "trait-lock-unmet"
+	ReasonNameLockUnmet     ExclusionReason = "name-lock-unmet"
+)
+
+// exclusionReasons lists every reason in the order they are checked
+var exclusionReasons = []ExclusionReason{ReasonReleasedAfterDeck, ReasonAspectMismatch, ReasonNameLockUnmet, ReasonTraitLockUnmet}
+
+// Describe explains the reason in words for the given card
+func (r ExclusionReason) Describe(card *Card) string {
+	switch r {
+	case ReasonReleasedAfterDeck:
+		return "the deck was last updated before the card was released"
+	case ReasonAspectMismatch:
+		return "the deck doesn't use the card's aspect"
+	case ReasonTraitLockUnmet:
+		return "the hero doesn't have the " + strings.Join(card.LockingTraits, " or ") + " trait"
+	case ReasonNameLockUnmet:
+		return "the hero isn't named " + strings.Join(card.LockingNames, " or ")
+	}
+	return string(r)
+}
+
+// DeckSummary is a deck that was counted towards a card's popularity
+type DeckSummary struct {
+	Id          int       `json:"id"`
+	HeroCode    string    `json:"heroCode"`
+	DateUpdated time.Time `json:"dateUpdated"`
+	Copies      int       `json:"copies"`
+}
+
+// PlanStep is one purchase of a buying plan
+type PlanStep struct {
+	Step            int        `json:"step"`
+	PackValue       *PackValue `json:"packValue"`
+	MarginalValue   int        `json:"marginalValue"`
+	CumulativeValue int        `json:"cumulativeValue"`
+}
+
+// BudgetPlan is the set of packs with the most marginal value that fits a budget
+type BudgetPlan struct {
+	Budget     float64 `json:"budget"`
+	TotalPrice float64 `json:"totalPrice"`
+	TotalValue int     `json:"totalValue"`
+	// Optimal is false if the search stopped early, in which case a better plan may exist
+	Optimal bool        `json:"optimal"`
+	Steps   []*PlanStep `json:"steps"`
 }
 
 type Hero struct {
@@ -80,6 +378,18 @@ type Hero struct {
 	Traits   []string `json:"traits"`
 }
 
+// CopiesIn returns how many copies of the card are in the pack
+func (c *Card) CopiesIn(packCode string) int {
+	if !utils.SliceContains(c.PackCodes, packCode) {
+		return 0
+	}
+	// cards stored before quantities were tracked count as a single copy
+	if q := c.PackQuantities[packCode]; q > 0 {
+		return q
+	}
+	return 1
+}
+
 func (h *Hero) Merge(h2 *Hero) {
 	v1, _ := strconv.Atoi(h.Code[:len(h.Code)-1])
 	v2, _ := strconv.Atoi(h2.Code[:len(h2.Code)-1])
@@ -0,0 +1,113 @@
+package controller
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestCalculateTypicalCopies(t *testing.T) {
+	tests := []struct {
+		name          string
+		card          *Card
+		inDecksCount  int
+		inDecksCopies int
+		want          int
+	}{
+		{"no decks", &Card{}, 0, 0, 1},
+		{"average rounds", &Card{}, 2, 5, 3},
+		{"average rounds down", &Card{}, 3, 4, 1},
+		{"limited by the default deck limit", &Card{}, 2, 9, cDefaultDeckLimit},
+		{"limited by the card's deck limit", &Card{DeckLimit: 1}, 2, 4, 1},
+		{"no card", nil, 1, 2, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cv := &CardValue{Card: tt.card, InDecksCount: tt.inDecksCount, InDecksCopies: tt.inDecksCopies}
+			cv.CalculateTypicalCopies()
+			if cv.TypicalCopies != tt.want {
+				t.Errorf("TypicalCopies = %v, want %v", cv.TypicalCopies, tt.want)
+			}
+		})
+	}
+}
+
+func TestCalculateNewMod(t *testing.T) {
+	tests := []struct {
+		name    string
+		typical int
+		owned   int
+		added   int
+		want    float64
+	}{
+		{"full playset added", 3, 0, 3, 1},
+		{"one more copy", 3, 1, 1, 1.0 / 3},
+		{"already have a playset", 3, 3, 1, 0},
+		{"more than needed", 3, 2, 3, 1.0 / 3},
+		{"nothing added", 2, 0, 0, 0},
+		{"no typical copies", 0, 0, 1, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cv := &CardValue{TypicalCopies: tt.typical, OwnedCopies: tt.owned, AddedCopies: tt.added}
+			cv.CalculateNewMod()
+			if math.Abs(cv.NewMod-tt.want) > 1e-9 {
+				t.Errorf("NewMod = %v, want %v", cv.NewMod, tt.want)
+			}
+		})
+	}
+}
+
+func TestWeightFor(t *testing.T) {
+	card := &Card{Code: "01060", Aspect: "aggression", TypeCode: "event", Traits: []string{"Attack."}}
+	tests := []struct {
+		name    string
+		weights *Weights
+		want    float64
+	}{
+		{"no weights", nil, 1},
+		{"empty weights", &Weights{}, 1},
+		{"aspect", &Weights{Aspects: map[string]float64{"aggression": 2}}, 2},
+		{"other aspect", &Weights{Aspects: map[string]float64{"justice": 2}}, 1},
+		{"aspect and type multiply", &Weights{Aspects: map[string]float64{"aggression": 2}, Types: map[string]float64{"event": 0.5}}, 1},
+		{"trait ignores case and period", &Weights{Traits: map[string]float64{"attack": 3}}, 3},
+		{"card overrides everything", &Weights{Aspects: map[string]float64{"aggression": 2}, Cards: map[string]float64{"01060": 0}}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.weights.WeightFor(card); got != tt.want {
+				t.Errorf("WeightFor() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecayWeight(t *testing.T) {
+	month := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	halfLifeLater := month.Add(time.Duration(6 * cDaysPerMonth * 24 * float64(time.Hour)))
+
+	tests := []struct {
+		name     string
+		month    string
+		halfLife float64
+		now      time.Time
+		want     float64
+	}{
+		{"no half life", "2024-01", 0, halfLifeLater, 1},
+		{"one half life", "2024-01", 6, halfLifeLater, 0.5},
+		{"two half lives", "2024-01", 3, halfLifeLater, 0.25},
+		{"future month", "2025-01", 6, halfLifeLater, 1},
+		{"invalid month", "nope", 6, halfLifeLater, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := decayWeight(tt.month, tt.halfLife, tt.now); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("decayWeight() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,27 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// loadPackPrices reads a JSON object of pack prices keyed by pack code, e.g. {"core": 59.99}
+func loadPackPrices(path string) (map[string]float64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	prices := map[string]float64{}
+	if err := json.Unmarshal(data, &prices); err != nil {
+		return nil, fmt.Errorf("failed to parse pack prices: %w", err)
+	}
+	for code, price := range prices {
+		if price <= 0 {
+			return nil, fmt.Errorf("invalid price for pack %v: %v", code, price)
+		}
+	}
+
+	return prices, nil
+}
@@ -0,0 +1,71 @@
+package controller
+
+import (
+	marvel "github.com/colbymilton/marchamps-valuator/internal/marvelcdb"
+)
+
+// Storage is the persistence layer used by the Valuator
+type Storage interface {
+	// Ping checks that the storage backend is reachable
+	Ping() error
+	// Clear empties all stored packs, cards, heroes, card values and pack values
+	// decklists, saved collections and meta data are kept
+	Clear()
+
+	// GetMeta returns the stored meta data, creating it if it doesn't exist yet
+	GetMeta() (*Meta, error)
+	SetMeta(meta *Meta) error
+
+	// GetPacks returns all packs sorted by their release date
+	GetPacks() ([]*marvel.Pack, error)
+	// AddPacks inserts packs, ignoring any that are already stored
+	AddPacks(packs []*marvel.Pack) error
+
+	GetCards() ([]*Card, error)
+	// GetCardsByAspect returns all cards with the given aspect (faction)
+	GetCardsByAspect(aspect string) ([]*Card, error)
+	// GetCardsBySetName returns all cards that are part of the given card set
+	GetCardsBySetName(setName string) ([]*Card, error)
+	// GetCardsFromPack returns all cards in the given pack that have one of the given aspects
+	GetCardsFromPack(packCode string, aspects []string) ([]*Card, error)
+	// SaveCards inserts or replaces cards
+	SaveCards(cards []*Card) error
+
+	GetHeroes() ([]*Hero, error)
+	// AddHeroes inserts heroes, ignoring any that are already stored
+	AddHeroes(heroes []*Hero) error
+
+	GetDecks() ([]*marvel.Decklist, error)
+	// GetLatestDeck returns the most recently created deck
+	// returns nil, nil if there are no decks
+	GetLatestDeck() (*marvel.Decklist, error)
+	// AddDecks inserts decks, ignoring any that are already stored
+	AddDecks(decks []*marvel.Decklist) error
+	CountDecks() int
+
+	// GetDeckDays returns every day whose decklists have been fetched
+	GetDeckDays() ([]*DeckDay, error)
+	// SaveDeckDay inserts or replaces the record of a fetched day
+	SaveDeckDay(day *DeckDay) error
+
+	GetCardValues() ([]*CardValue, error)
+	// GetCardValuesByCodes returns the card values for the given card codes
+	GetCardValuesByCodes(codes []string) ([]*CardValue, error)
+	// SaveCardValues inserts or replaces card values
+	SaveCardValues(cvs []*CardValue) error
+
+	GetPackValues() ([]*PackValue, error)
+	// SavePackValues inserts or replaces pack values
+	SavePackValues(pvs []*PackValue) error
+
+	// GetCollection returns the saved collection with the given id
+	// returns nil, nil if there is no such collection
+	GetCollection(id string) (*Collection, error)
+	// GetCollectionByShareCode returns the saved collection with the given share code
+	// returns nil, nil if there is no such collection
+	GetCollectionByShareCode(code string) (*Collection, error)
+	// SaveCollection inserts or replaces a saved collection
+	SaveCollection(coll *Collection) error
+	// DeleteCollection removes a saved collection, returning false if there was no such collection
+	DeleteCollection(id string) (bool, error)
+}
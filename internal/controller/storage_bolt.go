@@ -0,0 +1,142 @@
+package controller
+
+import (
+	marvel "github.com/colbymilton/marchamps-valuator/internal/marvelcdb"
+	"github.com/colbymilton/marchamps-valuator/internal/utils"
+	bw "github.com/colbymilton/marchamps-valuator/pkg/boltWrapper"
+)
+
+// BoltStorage is a Storage backed by an embedded, file-based BoltDB database
+type BoltStorage struct {
+	db *bw.BoltDB
+}
+
+func NewBoltStorage(path string) *BoltStorage {
+	return &BoltStorage{db: bw.NewBoltDB(path)}
+}
+
+func (bs *BoltStorage) Ping() error {
+	return bs.db.Ping()
+}
+
+func (bs *BoltStorage) Clear() {
+	bs.db.EmptyCollection(cCards)
+	bs.db.EmptyCollection(cPacks)
+	bs.db.EmptyCollection(cHeroes)
+	bs.db.EmptyCollection(cCardValues)
+	bs.db.EmptyCollection(cPackValues)
+}
+
+func (bs *BoltStorage) GetMeta() (*Meta, error) {
+	// add meta data (if it already exists, this will be ignored)
+	if err := bw.CreateMany(bs.db, cMeta, []*Meta{{Id: cMetaId}}); err != nil {
+		return nil, err
+	}
+
+	return bw.GetOne(bs.db, cMeta, func(m *Meta) bool { return m.Id == cMetaId }, nil)
+}
+
+func (bs *BoltStorage) SetMeta(meta *Meta) error {
+	return bw.ReplaceOneID(bs.db, cMeta, meta)
+}
+
+func (bs *BoltStorage) GetPacks() ([]*marvel.Pack, error) {
+	return bw.GetMany(bs.db, cPacks, nil, func(a, b *marvel.Pack) bool { return a.AvailableStr < b.AvailableStr })
+}
+
+func (bs *BoltStorage) AddPacks(packs []*marvel.Pack) error {
+	return bw.CreateMany(bs.db, cPacks, packs)
+}
+
+func (bs *BoltStorage) GetCards() ([]*Card, error) {
+	return bw.GetAll[Card](bs.db, cCards)
+}
+
+func (bs *BoltStorage) GetCardsByAspect(aspect string) ([]*Card, error) {
+	return bw.GetMany(bs.db, cCards, func(c *Card) bool { return c.Aspect == aspect }, nil)
+}
+
+func (bs *BoltStorage) GetCardsBySetName(setName string) ([]*Card, error) {
+	return bw.GetMany(bs.db, cCards, func(c *Card) bool { return c.CardSetName == setName }, nil)
+}
+
+func (bs *BoltStorage) GetCardsFromPack(packCode string, aspects []string) ([]*Card, error) {
+	return bw.GetMany(bs.db, cCards, func(c *Card) bool {
+		return utils.SliceContains(c.PackCodes, packCode) && utils.SliceContains(aspects, c.Aspect)
+	}, nil)
+}
+
+func (bs *BoltStorage) SaveCards(cards []*Card) error {
+	return bw.ReplaceManyID(bs.db, cCards, cards)
+}
+
+func (bs *BoltStorage) GetHeroes() ([]*Hero, error) {
+	return bw.GetAll[Hero](bs.db, cHeroes)
+}
+
+func (bs *BoltStorage) AddHeroes(heroes []*Hero) error {
+	return bw.CreateMany(bs.db, cHeroes, heroes)
+}
+
+func (bs *BoltStorage) GetDecks() ([]*marvel.Decklist, error) {
+	return bw.GetAll[marvel.Decklist](bs.db, cDecks)
+}
+
+func (bs *BoltStorage) GetLatestDeck() (*marvel.Decklist, error) {
+	return bw.GetOne(bs.db, cDecks, nil, func(a, b *marvel.Decklist) bool { return a.DateCreatedStr > b.DateCreatedStr })
+}
+
+func (bs *BoltStorage) AddDecks(decks []*marvel.Decklist) error {
+	return bw.CreateMany(bs.db, cDecks, decks)
+}
+
+func (bs *BoltStorage) CountDecks() int {
+	return bs.db.GetCollectionSize(cDecks)
+}
+
+func (bs *BoltStorage) GetDeckDays() ([]*DeckDay, error) {
+	return bw.GetAll[DeckDay](bs.db, cDeckDays)
+}
+
+func (bs *BoltStorage) SaveDeckDay(day *DeckDay) error {
+	return bw.ReplaceOneID(bs.db, cDeckDays, day)
+}
+
+func (bs *BoltStorage) GetCardValues() ([]*CardValue, error) {
+	return bw.GetAll[CardValue](bs.db, cCardValues)
+}
+
+func (bs *BoltStorage) GetCardValuesByCodes(codes []string) ([]*CardValue, error) {
+	return bw.GetMany(bs.db, cCardValues,
+		func(cv *CardValue) bool { return utils.SliceContains(codes, cv.Code) },
+		func(a, b *CardValue) bool { return a.Value > b.Value })
+}
+
+func (bs *BoltStorage) SaveCardValues(cvs []*CardValue) error {
+	return bw.ReplaceManyID(bs.db, cCardValues, cvs)
+}
+
+func (bs *BoltStorage) GetPackValues() ([]*PackValue, error) {
+	return bw.GetAll[PackValue](bs.db, cPackValues)
+}
+
+func (bs *BoltStorage) SavePackValues(pvs []*PackValue) error {
+	return bw.ReplaceManyID(bs.db, cPackValues, pvs)
+}
+
+func (bs *BoltStorage) GetCollection(id string) (*Collection, error) {
+	return bw.GetOne(bs.db, cCollections, func(c *Collection) bool { return c.Id == id }, nil)
+}
+
+func (bs *BoltStorage) GetCollectionByShareCode(code string) (*Collection, error) {
+	return bw.GetOne(bs.db, cCollections, func(c *Collection) bool { return c.ShareCode == code }, nil)
+}
+
+func (bs *BoltStorage) SaveCollection(coll *Collection) error {
+	return bw.ReplaceOneID(bs.db, cCollections, coll)
+}
+
+func (bs *BoltStorage) DeleteCollection(id string) (bool, error) {
+	deleted, err := bw.DeleteMany(bs.db, cCollections, func(c *Collection) bool { return c.Id == id })
+	return deleted > 0, err
+}
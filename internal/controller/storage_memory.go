@@ -0,0 +1,142 @@
+package controller
+
+import (
+	marvel "github.com/colbymilton/marchamps-valuator/internal/marvelcdb"
+	"github.com/colbymilton/marchamps-valuator/internal/utils"
+	memw "github.com/colbymilton/marchamps-valuator/pkg/memoryWrapper"
+)
+
+// MemoryStorage is a Storage that keeps everything in memory
+type MemoryStorage struct {
+	db *memw.MemoryDB
+}
+
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{db: memw.NewMemoryDB()}
+}
+
+func (ms *MemoryStorage) Ping() error {
+	return ms.db.Ping()
+}
+
+func (ms *MemoryStorage) Clear() {
+	ms.db.EmptyCollection(cCards)
+	ms.db.EmptyCollection(cPacks)
+	ms.db.EmptyCollection(cHeroes)
+	ms.db.EmptyCollection(cCardValues)
+	ms.db.EmptyCollection(cPackValues)
+}
+
+func (ms *MemoryStorage) GetMeta() (*Meta, error) {
+	// add meta data (if it already exists, this will be ignored)
+	if err := memw.CreateMany(ms.db, cMeta, []*Meta{{Id: cMetaId}}); err != nil {
+		return nil, err
+	}
+
+	return memw.GetOne(ms.db, cMeta, func(m *Meta) bool { return m.Id == cMetaId }, nil)
+}
+
+func (ms *MemoryStorage) SetMeta(meta *Meta) error {
+	return memw.ReplaceOneID(ms.db, cMeta, meta)
+}
+
+func (ms *MemoryStorage) GetPacks() ([]*marvel.Pack, error) {
+	return memw.GetMany(ms.db, cPacks, nil, func(a, b *marvel.Pack) bool { return a.AvailableStr < b.AvailableStr })
+}
+
+func (ms *MemoryStorage) AddPacks(packs []*marvel.Pack) error {
+	return memw.CreateMany(ms.db, cPacks, packs)
+}
+
+func (ms *MemoryStorage) GetCards() ([]*Card, error) {
+	return memw.GetAll[Card](ms.db, cCards)
+}
+
+func (ms *MemoryStorage) GetCardsByAspect(aspect string) ([]*Card, error) {
+	return memw.GetMany(ms.db, cCards, func(c *Card) bool { return c.Aspect == aspect }, nil)
+}
+
+func (ms *MemoryStorage) GetCardsBySetName(setName string) ([]*Card, error) {
+	return memw.GetMany(ms.db, cCards, func(c *Card) bool { return c.CardSetName == setName }, nil)
+}
+
+func (ms *MemoryStorage) GetCardsFromPack(packCode string, aspects []string) ([]*Card, error) {
+	return memw.GetMany(ms.db, cCards, func(c *Card) bool {
+		return utils.SliceContains(c.PackCodes, packCode) && utils.SliceContains(aspects, c.Aspect)
+	}, nil)
+}
+
+func (ms *MemoryStorage) SaveCards(cards []*Card) error {
+	return memw.ReplaceManyID(ms.db, cCards, cards)
+}
+
+func (ms *MemoryStorage) GetHeroes() ([]*Hero, error) {
+	return memw.GetAll[Hero](ms.db, cHeroes)
+}
+
+func (ms *MemoryStorage) AddHeroes(heroes []*Hero) error {
+	return memw.CreateMany(ms.db, cHeroes, heroes)
+}
+
+func (ms *MemoryStorage) GetDecks() ([]*marvel.Decklist, error) {
+	return memw.GetAll[marvel.Decklist](ms.db, cDecks)
+}
+
+func (ms *MemoryStorage) GetLatestDeck() (*marvel.Decklist, error) {
+	return memw.GetOne(ms.db, cDecks, nil, func(a, b *marvel.Decklist) bool { return a.DateCreatedStr > b.DateCreatedStr })
+}
+
+func (ms *MemoryStorage) AddDecks(decks []*marvel.Decklist) error {
+	return memw.CreateMany(ms.db, cDecks, decks)
+}
+
+func (ms *MemoryStorage) CountDecks() int {
+	return ms.db.GetCollectionSize(cDecks)
+}
+
+func (ms *MemoryStorage) GetDeckDays() ([]*DeckDay, error) {
+	return memw.GetAll[DeckDay](ms.db, cDeckDays)
+}
+
+func (ms *MemoryStorage) SaveDeckDay(day *DeckDay) error {
+	return memw.ReplaceOneID(ms.db, cDeckDays, day)
+}
+
+func (ms *MemoryStorage) GetCardValues() ([]*CardValue, error) {
+	return memw.GetAll[CardValue](ms.db, cCardValues)
+}
+
+func (ms *MemoryStorage) GetCardValuesByCodes(codes []string) ([]*CardValue, error) {
+	return memw.GetMany(ms.db, cCardValues,
+		func(cv *CardValue) bool { return utils.SliceContains(codes, cv.Code) },
+		func(a, b *CardValue) bool { return a.Value > b.Value })
+}
+
+func (ms *MemoryStorage) SaveCardValues(cvs []*CardValue) error {
+	return memw.ReplaceManyID(ms.db, cCardValues, cvs)
+}
+
+func (ms *MemoryStorage) GetPackValues() ([]*PackValue, error) {
+	return memw.GetAll[PackValue](ms.db, cPackValues)
+}
+
+func (ms *MemoryStorage) SavePackValues(pvs []*PackValue) error {
+	return memw.ReplaceManyID(ms.db, cPackValues, pvs)
+}
+
+func (ms *MemoryStorage) GetCollection(id string) (*Collection, error) {
+	return memw.GetOne(ms.db, cCollections, func(c *Collection) bool { return c.Id == id }, nil)
+}
+
+func (ms *MemoryStorage) GetCollectionByShareCode(code string) (*Collection, error) {
+	return memw.GetOne(ms.db, cCollections, func(c *Collection) bool { return c.ShareCode == code }, nil)
+}
+
+func (ms *MemoryStorage) SaveCollection(coll *Collection) error {
+	return memw.ReplaceOneID(ms.db, cCollections, coll)
+}
+
+func (ms *MemoryStorage) DeleteCollection(id string) (bool, error) {
+	deleted, err := memw.DeleteMany(ms.db, cCollections, func(c *Collection) bool { return c.Id == id })
+	return deleted > 0, err
+}
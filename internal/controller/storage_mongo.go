@@ -0,0 +1,156 @@
+package controller
+
+import (
+	marvel "github.com/colbymilton/marchamps-valuator/internal/marvelcdb"
+	mw "github.com/colbymilton/marchamps-valuator/pkg/mongoWrapper"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	cPacks       = "packs"
+	cCards       = "cards"
+	cDecks       = "decks"
+	cDeckDays    = "deck-days"
+	cHeroes      = "heroes"
+	cCardValues  = "card-values"
+	cPackValues  = "pack-values"
+	cMeta        = "meta"
+	cCollections = "collections"
+)
+
+// MongoStorage is a Storage backed by a MongoDB database
+type MongoStorage struct {
+	db *mw.MongoDB
+}
+
+func NewMongoStorage(uri, database string) *MongoStorage {
+	return &MongoStorage{db: mw.NewMongoDB(uri, database)}
+}
+
+func (ms *MongoStorage) Ping() error {
+	return ms.db.Ping()
+}
+
+func (ms *MongoStorage) Clear() {
+	ms.db.EmptyCollection(cCards)
+	ms.db.EmptyCollection(cPacks)
+	ms.db.EmptyCollection(cHeroes)
+	ms.db.EmptyCollection(cCardValues)
+	ms.db.EmptyCollection(cPackValues)
+}
+
+func (ms *MongoStorage) GetMeta() (*Meta, error) {
+	// add meta data (if it already exists, this will be ignored)
+	if err := mw.CreateMany[Meta](ms.db, cMeta, []*Meta{{Id: cMetaId}}); err != nil {
+		return nil, err
+	}
+
+	return mw.GetOne[Meta](ms.db, cMeta, mw.BuildEqualsFilter("_id", cMetaId), mw.BsonNoneM)
+}
+
+func (ms *MongoStorage) SetMeta(meta *Meta) error {
+	return mw.ReplaceOneID(ms.db, cMeta, meta)
+}
+
+func (ms *MongoStorage) GetPacks() ([]*marvel.Pack, error) {
+	return mw.GetMany[marvel.Pack](ms.db, cPacks, mw.BsonNoneD, bson.M{"availablestr": 1})
+}
+
+func (ms *MongoStorage) AddPacks(packs []*marvel.Pack) error {
+	return mw.CreateMany(ms.db, cPacks, packs)
+}
+
+func (ms *MongoStorage) GetCards() ([]*Card, error) {
+	return mw.GetAll[Card](ms.db, cCards)
+}
+
+func (ms *MongoStorage) GetCardsByAspect(aspect string) ([]*Card, error) {
+	return mw.GetMany[Card](ms.db, cCards, mw.BuildEqualsFilter("aspect", aspect), mw.BsonNoneM)
+}
+
+func (ms *MongoStorage) GetCardsBySetName(setName string) ([]*Card, error) {
+	return mw.GetMany[Card](ms.db, cCards, mw.BuildEqualsFilter("cardsetname", setName), mw.BsonNoneM)
+}
+
+func (ms *MongoStorage) GetCardsFromPack(packCode string, aspects []string) ([]*Card, error) {
+	filter := mw.BuildAndFilter([]bson.D{
+		mw.BuildEqualsFilter("packcodes", packCode),
+		mw.BuildEqualsFilter("aspect", bson.D{{Key: "$in", Value: aspects}}),
+	})
+
+	return mw.GetMany[Card](ms.db, cCards, filter, mw.BsonNoneM)
+}
+
+func (ms *MongoStorage) SaveCards(cards []*Card) error {
+	return mw.ReplaceManyID(ms.db, cCards, cards)
+}
+
+func (ms *MongoStorage) GetHeroes() ([]*Hero, error) {
+	return mw.GetAll[Hero](ms.db, cHeroes)
+}
+
+func (ms *MongoStorage) AddHeroes(heroes []*Hero) error {
+	return mw.CreateMany(ms.db, cHeroes, heroes)
+}
+
+func (ms *MongoStorage) GetDecks() ([]*marvel.Decklist, error) {
+	return mw.GetAll[marvel.Decklist](ms.db, cDecks)
+}
+
+func (ms *MongoStorage) GetLatestDeck() (*marvel.Decklist, error) {
+	return mw.GetOne[marvel.Decklist](ms.db, cDecks, mw.BsonNoneD, bson.M{"datecreatedstr": -1})
+}
+
+func (ms *MongoStorage) AddDecks(decks []*marvel.Decklist) error {
+	return mw.CreateMany(ms.db, cDecks, decks)
+}
+
+func (ms *MongoStorage) CountDecks() int {
+	return ms.db.GetCollectionSize(cDecks)
+}
+
+func (ms *MongoStorage) GetDeckDays() ([]*DeckDay, error) {
+	return mw.GetAll[DeckDay](ms.db, cDeckDays)
+}
+
+func (ms *MongoStorage) SaveDeckDay(day *DeckDay) error {
+	return mw.ReplaceOneID(ms.db, cDeckDays, day)
+}
+
+func (ms *MongoStorage) GetCardValues() ([]*CardValue, error) {
+	return mw.GetAll[CardValue](ms.db, cCardValues)
+}
+
+func (ms *MongoStorage) GetCardValuesByCodes(codes []string) ([]*CardValue, error) {
+	filter := mw.BuildEqualsFilter("_id", bson.D{{Key: "$in", Value: codes}})
+	return mw.GetMany[CardValue](ms.db, cCardValues, filter, bson.M{"value": -1})
+}
+
+func (ms *MongoStorage) SaveCardValues(cvs []*CardValue) error {
+	return mw.ReplaceManyID(ms.db, cCardValues, cvs)
+}
+
+func (ms *MongoStorage) GetPackValues() ([]*PackValue, error) {
+	return mw.GetAll[PackValue](ms.db, cPackValues)
+}
+
+func (ms *MongoStorage) SavePackValues(pvs []*PackValue) error {
+	return mw.ReplaceManyID(ms.db, cPackValues, pvs)
+}
+
+func (ms *MongoStorage) GetCollection(id string) (*Collection, error) {
+	return mw.GetOne[Collection](ms.db, cCollections, mw.BuildEqualsFilter("_id", id), mw.BsonNoneM)
+}
+
+func (ms *MongoStorage) GetCollectionByShareCode(code string) (*Collection, error) {
+	return mw.GetOne[Collection](ms.db, cCollections, mw.BuildEqualsFilter("sharecode", code), mw.BsonNoneM)
+}
+
+func (ms *MongoStorage) SaveCollection(coll *Collection) error {
+	return mw.ReplaceOneID(ms.db, cCollections, coll)
+}
+
+func (ms *MongoStorage) DeleteCollection(id string) (bool, error) {
+	deleted, err := mw.DeleteMany(ms.db, cCollections, mw.BuildEqualsFilter("_id", id))
+	return deleted > 0, err
+}
@@ -0,0 +1,129 @@
+package marvel
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// ChangeTracker is implemented by clients that know whether the catalogue (packs and cards)
+// changed the last time it was fetched
+type ChangeTracker interface {
+	CatalogueChanged() bool
+}
+
+// cacheEntry holds the validators of a cached response
+type cacheEntry struct {
+	ETag         string `json:"etag"`
+	LastModified string `json:"lastModified"`
+	Hash         string `json:"hash"`
+
+	body []byte
+}
+
+// httpCache is an on-disk cache of responses, stored in the same layout that a ReplayClient reads
+type httpCache struct {
+	dir     string
+	changed map[string]bool
+	mutex   sync.Mutex
+}
+
+func newHttpCache(dir string) *httpCache {
+	return &httpCache{dir: dir, changed: make(map[string]bool)}
+}
+
+// load returns the cached response for the endpoint, or nil if there isn't one
+func (c *httpCache) load(endpoint string) *cacheEntry {
+	path := endpointPath(c.dir, endpoint)
+	body, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	data, err := os.ReadFile(path + ".meta")
+	if err != nil {
+		return nil
+	}
+
+	entry := &cacheEntry{}
+	if err := json.Unmarshal(data, entry); err != nil {
+		return nil
+	}
+	entry.body = body
+	return entry
+}
+
+// store saves a response for the endpoint and records whether its content changed
+func (c *httpCache) store(endpoint string, body []byte, header http.Header) error {
+	sum := sha256.Sum256(body)
+	entry := &cacheEntry{
+		ETag:         header.Get("ETag"),
+		LastModified: header.Get("Last-Modified"),
+		Hash:         hex.EncodeToString(sum[:]),
+	}
+
+	// servers that don't send validators are compared by content instead
+	old := c.load(endpoint)
+	c.setChanged(endpoint, old == nil || old.Hash != entry.Hash)
+
+	path := endpointPath(c.dir, endpoint)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, body, 0644); err != nil {
+		return err
+	}
+	return os.WriteFile(path+".meta", data, 0644)
+}
+
+// conditionalHeaders returns the headers that ask the server to only respond if the entry is stale
+func (entry *cacheEntry) conditionalHeaders() map[string]string {
+	headers := map[string]string{}
+	if entry == nil {
+		return headers
+	}
+	if entry.ETag != "" {
+		headers["If-None-Match"] = entry.ETag
+	}
+	if entry.LastModified != "" {
+		headers["If-Modified-Since"] = entry.LastModified
+	}
+	return headers
+}
+
+func (c *httpCache) setChanged(endpoint string, changed bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.changed[endpoint] = changed
+}
+
+// isChanged reports whether the last fetch of the endpoint returned new content
+// endpoints that haven't been fetched yet count as changed
+func (c *httpCache) isChanged(endpoint string) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	changed, ok := c.changed[endpoint]
+	return !ok || changed
+}
+
+// rawDecoder is a sling.ResponseDecoder that reads the response body into a *[]byte
+type rawDecoder struct{}
+
+func (rawDecoder) Decode(resp *http.Response, v interface{}) error {
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	*(v.(*[]byte)) = body
+	return nil
+}
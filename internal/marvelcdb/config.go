@@ -0,0 +1,133 @@
+package marvel
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultBaseAddr        = "https://marvelcdb.com/api/public/"
+	defaultTimeout         = time.Second * 30
+	defaultUserAgent       = "marchamps-valuator (+https://marchampsvalue.com)"
+	defaultRequestInterval = time.Millisecond * 250
+	defaultMaxRetries      = 3
+	defaultRetryBackoff    = time.Second
+)
+
+// ClientConfig holds the settings used to talk to marvelcdb (or a mirror of it)
+type ClientConfig struct {
+	BaseAddr  string
+	Timeout   time.Duration
+	UserAgent string
+
+	// RequestInterval is the minimum time between two requests
+	RequestInterval time.Duration
+	// MaxRetries is how many times a transient failure is retried
+	MaxRetries int
+	// RetryBackoff is the delay before the first retry, it doubles on every retry after that
+	RetryBackoff time.Duration
+
+	// CacheDir is where card and pack downloads are cached, caching is disabled if empty
+	CacheDir string
+
+	// CAFile is an optional PEM file of extra certificate authorities to trust
+	CAFile string
+	// InsecureSkipVerify disables TLS certificate verification, for local stand-ins only
+	InsecureSkipVerify bool
+}
+
+// DefaultConfig returns the settings for the public marvelcdb.com api
+func DefaultConfig() ClientConfig {
+	return ClientConfig{
+		BaseAddr:        defaultBaseAddr,
+		Timeout:         defaultTimeout,
+		UserAgent:       defaultUserAgent,
+		RequestInterval: defaultRequestInterval,
+		MaxRetries:      defaultMaxRetries,
+		RetryBackoff:    defaultRetryBackoff,
+	}
+}
+
+// ConfigFromEnv returns the DefaultConfig overridden by any MARVELCDB_* environment variables
+func ConfigFromEnv() (ClientConfig, error) {
+	cfg := DefaultConfig()
+
+	if baseAddr := os.Getenv("MARVELCDB_BASE_URL"); baseAddr != "" {
+		cfg.BaseAddr = baseAddr
+	}
+	if timeout := os.Getenv("MARVELCDB_TIMEOUT"); timeout != "" {
+		d, err := time.ParseDuration(timeout)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid MARVELCDB_TIMEOUT: %w", err)
+		}
+		cfg.Timeout = d
+	}
+	if userAgent := os.Getenv("MARVELCDB_USER_AGENT"); userAgent != "" {
+		cfg.UserAgent = userAgent
+	}
+	if interval := os.Getenv("MARVELCDB_REQUEST_INTERVAL"); interval != "" {
+		d, err := time.ParseDuration(interval)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid MARVELCDB_REQUEST_INTERVAL: %w", err)
+		}
+		cfg.RequestInterval = d
+	}
+	if maxRetries := os.Getenv("MARVELCDB_MAX_RETRIES"); maxRetries != "" {
+		i, err := strconv.Atoi(maxRetries)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid MARVELCDB_MAX_RETRIES: %w", err)
+		}
+		cfg.MaxRetries = i
+	}
+	if backoff := os.Getenv("MARVELCDB_RETRY_BACKOFF"); backoff != "" {
+		d, err := time.ParseDuration(backoff)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid MARVELCDB_RETRY_BACKOFF: %w", err)
+		}
+		cfg.RetryBackoff = d
+	}
+	cfg.CacheDir = os.Getenv("MARVELCDB_CACHE_DIR")
+	cfg.CAFile = os.Getenv("MARVELCDB_CA_FILE")
+	cfg.InsecureSkipVerify = os.Getenv("MARVELCDB_TLS_INSECURE") == "true"
+
+	return cfg, nil
+}
+
+// httpClient builds the http.Client described by the config
+func (cfg ClientConfig) httpClient() (*http.Client, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: cfg.InsecureSkipVerify}
+
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %v", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+
+	return &http.Client{Timeout: cfg.Timeout, Transport: transport}, nil
+}
+
+// baseURL returns the configured base address with a trailing slash
+func (cfg ClientConfig) baseURL() string {
+	if !strings.HasSuffix(cfg.BaseAddr, "/") {
+		return cfg.BaseAddr + "/"
+	}
+	return cfg.BaseAddr
+}
@@ -0,0 +1,58 @@
+package marvel
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// ErrNoDecklists is returned by GetDecklists when marvelcdb has no decks for the requested day
+var ErrNoDecklists = errors.New("no decklists for the requested day")
+
+// StatusError is returned when marvelcdb responds with an unexpected status code
+type StatusError struct {
+	StatusCode int
+	Status     string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected response (status %v): %v", e.StatusCode, e.Status)
+}
+
+// Temporary reports whether the request is worth retrying
+func (e *StatusError) Temporary() bool {
+	switch e.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// networkError is returned when a request could not be sent or no response was received
+type networkError struct {
+	err error
+}
+
+func (e *networkError) Error() string {
+	return e.err.Error()
+}
+
+func (e *networkError) Unwrap() error {
+	return e.err
+}
+
+// IsUpstreamError reports whether the error came from marvelcdb being unreachable or responding badly
+func IsUpstreamError(err error) bool {
+	var se *StatusError
+	var ne *networkError
+	return errors.As(err, &se) || errors.As(err, &ne)
+}
+
+// decklistsError converts the 500 that marvelcdb returns on days without decks into ErrNoDecklists
+func decklistsError(err error) error {
+	var se *StatusError
+	if errors.As(err, &se) && se.StatusCode == http.StatusInternalServerError {
+		return ErrNoDecklists
+	}
+	return err
+}
@@ -0,0 +1,27 @@
+package marvel
+
+import (
+	"sync"
+	"time"
+)
+
+// limiter spaces out requests so that they are at least interval apart
+type limiter struct {
+	interval time.Duration
+	next     time.Time
+	mutex    sync.Mutex
+}
+
+// wait blocks until the caller is allowed to send its next request
+func (l *limiter) wait() {
+	l.mutex.Lock()
+	now := time.Now()
+	at := l.next
+	if at.Before(now) {
+		at = now
+	}
+	l.next = at.Add(l.interval)
+	l.mutex.Unlock()
+
+	time.Sleep(time.Until(at))
+}
@@ -1,6 +1,8 @@
 package marvel
 
 import (
+	"encoding/json"
+	"fmt"
 	"strings"
 	"time"
 )
@@ -12,6 +14,18 @@ type Decklist struct {
 	Slots          map[string]int `json:"slots"`
 	Meta           string         `json:"meta"`
 	HeroCode       string         `json:"investigator_code"`
+
+	// parsedMeta caches the result of ParseMeta
+	parsedMeta *DecklistMeta
+}
+
+// DecklistMeta is the decoded form of Decklist.Meta
+type DecklistMeta struct {
+	Aspect string `json:"aspect"`
+	// Aspect2 is the second aspect of heroes that run two (like Spider-Woman)
+	Aspect2 string `json:"aspect2"`
+	// Extra holds every other field of the meta data
+	Extra map[string]json.RawMessage `json:"-"`
 }
 
 func (d *Decklist) DateCreated() time.Time {
@@ -24,19 +38,40 @@ func (d *Decklist) DateUpdated() time.Time {
 	return t
 }
 
-func (d *Decklist) Aspects() []string {
-	a := make([]string, 0)
-	if strings.Contains(d.Meta, "aggression") {
-		a = append(a, "aggression")
+// ParseMeta decodes the deck's meta data, which marvelcdb provides as a JSON encoded string
+func (d *Decklist) ParseMeta() (*DecklistMeta, error) {
+	if d.parsedMeta != nil {
+		return d.parsedMeta, nil
 	}
-	if strings.Contains(d.Meta, "justice") {
-		a = append(a, "justice")
+
+	meta := &DecklistMeta{}
+	if strings.TrimSpace(d.Meta) != "" {
+		if err := json.Unmarshal([]byte(d.Meta), meta); err != nil {
+			return nil, fmt.Errorf("invalid meta for deck %v: %w", d.Id, err)
+		}
+		if err := json.Unmarshal([]byte(d.Meta), &meta.Extra); err != nil {
+			return nil, fmt.Errorf("invalid meta for deck %v: %w", d.Id, err)
+		}
+		delete(meta.Extra, "aspect")
+		delete(meta.Extra, "aspect2")
 	}
-	if strings.Contains(d.Meta, "leadership") {
-		a = append(a, "leadership")
+
+	d.parsedMeta = meta
+	return meta, nil
+}
+
+// Aspects returns the aspects that the deck is running
+// decks with unreadable meta data have no aspects
+func (d *Decklist) Aspects() []string {
+	a := make([]string, 0)
+	meta, err := d.ParseMeta()
+	if err != nil {
+		return a
 	}
-	if strings.Contains(d.Meta, "protection") {
-		a = append(a, "protection")
+	for _, aspect := range []string{meta.Aspect, meta.Aspect2} {
+		if aspect != "" {
+			a = append(a, strings.ToLower(aspect))
+		}
 	}
 	return a
 }
@@ -51,6 +86,8 @@ type Card struct {
 	Traits      string   `json:"traits"`
 	DuplicateOf string   `json:"duplicate_of_code"`
 	DuplicateBy []string `json:"duplicated_by"`
+	Quantity    int      `json:"quantity"`
+	DeckLimit   int      `json:"deck_limit"`
 	Text        string   `json:"text"`
 	CardSetName string   `json:"card_set_name"`
 	LinkedCard  *Card    `json:"linked_card"`
@@ -0,0 +1,64 @@
+package marvel
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestDecklistParseMeta(t *testing.T) {
+	tests := []struct {
+		name      string
+		meta      string
+		wantErr   bool
+		aspect    string
+		aspect2   string
+		extraKeys []string
+		aspects   []string
+	}{
+		{name: "empty", meta: "", aspects: []string{}},
+		{name: "single aspect", meta: `{"aspect":"justice"}`, aspect: "justice", aspects: []string{"justice"}},
+		{name: "two aspects", meta: `{"aspect":"Leadership","aspect2":"justice"}`, aspect: "Leadership", aspect2: "justice", aspects: []string{"leadership", "justice"}},
+		{name: "extra fields", meta: `{"aspect":"protection","pool":true}`, aspect: "protection", extraKeys: []string{"pool"}, aspects: []string{"protection"}},
+		{name: "invalid", meta: `{"aspect":`, wantErr: true, aspects: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deck := &Decklist{Id: 1, Meta: tt.meta}
+			meta, err := deck.ParseMeta()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseMeta() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil {
+				if meta.Aspect != tt.aspect || meta.Aspect2 != tt.aspect2 {
+					t.Errorf("ParseMeta() aspects = %q, %q, want %q, %q", meta.Aspect, meta.Aspect2, tt.aspect, tt.aspect2)
+				}
+				for _, key := range tt.extraKeys {
+					if _, ok := meta.Extra[key]; !ok {
+						t.Errorf("ParseMeta() extra is missing %q", key)
+					}
+				}
+				if _, ok := meta.Extra["aspect"]; ok {
+					t.Errorf("ParseMeta() extra contains aspect")
+				}
+			}
+
+			if got := deck.Aspects(); !reflect.DeepEqual(got, tt.aspects) {
+				t.Errorf("Aspects() = %q, want %q", got, tt.aspects)
+			}
+		})
+	}
+}
+
+func TestDecklistMetaFromJSON(t *testing.T) {
+	// marvelcdb sends the meta data as a JSON encoded string
+	data := `{"id":7,"meta":"{\"aspect\":\"aggression\"}","investigator_code":"01001a"}`
+	deck := &Decklist{}
+	if err := json.Unmarshal([]byte(data), deck); err != nil {
+		t.Fatal(err)
+	}
+	if got := deck.Aspects(); !reflect.DeepEqual(got, []string{"aggression"}) {
+		t.Errorf("Aspects() = %q, want [aggression]", got)
+	}
+}
@@ -0,0 +1,143 @@
+package marvel
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ReplayClient is a Client that serves recorded marvelcdb responses from a directory.
+// Each endpoint is read from "<dir>/<endpoint>.json", e.g. "<dir>/decklists/by_date/2020-01-01.json".
+type ReplayClient struct {
+	dir string
+}
+
+func NewReplayClient(dir string) (*ReplayClient, error) {
+	if info, err := os.Stat(dir); err != nil {
+		return nil, err
+	} else if !info.IsDir() {
+		return nil, fmt.Errorf("%v is not a directory", dir)
+	}
+
+	return &ReplayClient{dir: dir}, nil
+}
+
+func endpointPath(dir, endpoint string) string {
+	return filepath.Join(dir, filepath.FromSlash(endpoint)+".json")
+}
+
+func (rcli *ReplayClient) get(endpoint string, body any) error {
+	path := endpointPath(rcli.dir, endpoint)
+	log.Println("replaying:", path)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		// marvelcdb returns a 500 for anything it doesn't have (like days without decks)
+		return &StatusError{StatusCode: http.StatusInternalServerError, Status: "500 Internal Server Error"}
+	} else if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, body)
+}
+
+// GetDecklists returns the recorded Decklists for the specified day
+// returns ErrNoDecklists if none were recorded
+func (rcli *ReplayClient) GetDecklists(date time.Time) ([]*Decklist, error) {
+	var decklists []*Decklist
+	err := rcli.get("decklists/by_date/"+date.Format("2006-01-02"), &decklists)
+	return decklists, decklistsError(err)
+}
+
+// GetAllCards returns all the recorded cards
+func (rcli *ReplayClient) GetAllCards() ([]*Card, error) {
+	var cards []*Card
+	err := rcli.get("cards", &cards)
+	return cards, err
+}
+
+// GetCards returns all recorded cards that are part of the specified pack
+func (rcli *ReplayClient) GetCards(packCode string) ([]*Card, error) {
+	var cards []*Card
+	err := rcli.get("cards/"+packCode, &cards)
+	return cards, err
+}
+
+// GetAllPacks returns all the recorded packs
+func (rcli *ReplayClient) GetAllPacks() ([]*Pack, error) {
+	var packs []*Pack
+	err := rcli.get("packs", &packs)
+	return packs, err
+}
+
+// Recorder is a Client that passes requests through to another Client
+// and saves every successful response in a directory that a ReplayClient can read
+type Recorder struct {
+	cli Client
+	dir string
+}
+
+func NewRecorder(cli Client, dir string) *Recorder {
+	return &Recorder{cli: cli, dir: dir}
+}
+
+func (rec *Recorder) save(endpoint string, body any) error {
+	path := endpointPath(rec.dir, endpoint)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// GetDecklists returns all Decklists posted on the specified day and records them
+func (rec *Recorder) GetDecklists(date time.Time) ([]*Decklist, error) {
+	decklists, err := rec.cli.GetDecklists(date)
+	if err != nil {
+		return decklists, err
+	}
+	return decklists, rec.save("decklists/by_date/"+date.Format("2006-01-02"), decklists)
+}
+
+// GetAllCards returns all the cards and records them
+func (rec *Recorder) GetAllCards() ([]*Card, error) {
+	cards, err := rec.cli.GetAllCards()
+	if err != nil {
+		return cards, err
+	}
+	return cards, rec.save("cards", cards)
+}
+
+// GetCards returns all cards that are part of the specified pack and records them
+func (rec *Recorder) GetCards(packCode string) ([]*Card, error) {
+	cards, err := rec.cli.GetCards(packCode)
+	if err != nil {
+		return cards, err
+	}
+	return cards, rec.save("cards/"+packCode, cards)
+}
+
+// GetAllPacks returns all the packs and records them
+func (rec *Recorder) GetAllPacks() ([]*Pack, error) {
+	packs, err := rec.cli.GetAllPacks()
+	if err != nil {
+		return packs, err
+	}
+	return packs, rec.save("packs", packs)
+}
+
+// CatalogueChanged reports whether the wrapped client saw a changed catalogue
+// clients that don't track changes always count as changed
+func (rec *Recorder) CatalogueChanged() bool {
+	if tracker, ok := rec.cli.(ChangeTracker); ok {
+		return tracker.CatalogueChanged()
+	}
+	return true
+}
@@ -0,0 +1,37 @@
+package marvel
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRecorderForwardsCatalogueChanged(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"code":"core","name":"Core Set"}]`))
+	}))
+	defer srv.Close()
+	rec := NewRecorder(newTestClient(t, srv, t.TempDir()), t.TempDir())
+
+	var _ ChangeTracker = rec
+	for i, wantChanged := range []bool{true, false} {
+		if _, err := rec.GetAllPacks(); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := rec.GetAllCards(); err != nil {
+			t.Fatal(err)
+		}
+		if changed := rec.CatalogueChanged(); changed != wantChanged {
+			t.Errorf("download %v: CatalogueChanged() = %v, want %v", i+1, changed, wantChanged)
+		}
+	}
+
+	// a replay client doesn't track changes
+	rcli, err := NewReplayClient(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !NewRecorder(rcli, t.TempDir()).CatalogueChanged() {
+		t.Error("CatalogueChanged() = false for a client that doesn't track changes")
+	}
+}
@@ -1,7 +1,8 @@
 package marvel
 
 import (
-	"fmt"
+	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 	"time"
@@ -9,53 +10,178 @@ import (
 	"github.com/dghubble/sling"
 )
 
-const baseAddr = "http://marvelcdb.com/api/public/"
+// Client is the set of marvelcdb endpoints used by the valuator
+type Client interface {
+	GetAllPacks() ([]*Pack, error)
+	GetAllCards() ([]*Card, error)
+	GetCards(packCode string) ([]*Card, error)
+	GetDecklists(date time.Time) ([]*Decklist, error)
+}
 
+// MarvelClient is a Client that talks to marvelcdb.com
 type MarvelClient struct {
+	baseAddr  string
+	userAgent string
+	http      *http.Client
+
+	limiter      *limiter
+	maxRetries   int
+	retryBackoff time.Duration
+
+	// cache is nil if caching is disabled
+	cache *httpCache
 }
 
+// NewClient returns a MarvelClient configured from the environment
 func NewClient() (*MarvelClient, error) {
-	mcli := &MarvelClient{}
+	cfg, err := ConfigFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	return NewClientWithConfig(cfg)
+}
+
+func NewClientWithConfig(cfg ClientConfig) (*MarvelClient, error) {
+	httpCli, err := cfg.httpClient()
+	if err != nil {
+		return nil, err
+	}
+
+	mcli := &MarvelClient{
+		baseAddr:     cfg.baseURL(),
+		userAgent:    cfg.UserAgent,
+		http:         httpCli,
+		limiter:      &limiter{interval: cfg.RequestInterval},
+		maxRetries:   cfg.MaxRetries,
+		retryBackoff: cfg.RetryBackoff,
+	}
+	if cfg.CacheDir != "" {
+		mcli.cache = newHttpCache(cfg.CacheDir)
+	}
 	return mcli, nil
 }
 
+// get requests the endpoint and decodes the response into body
 func (mcli *MarvelClient) get(endpoint string, body any) error {
-	log.Println("sending:", baseAddr+endpoint)
-	resp, err := sling.New().Get(baseAddr + endpoint).ReceiveSuccess(body)
+	_, raw, err := mcli.request(endpoint, nil)
+	if err != nil {
+		return err
+	}
+	return decode(raw, body)
+}
+
+// getCached is like get, but only downloads the endpoint again if it has changed since it was cached
+func (mcli *MarvelClient) getCached(endpoint string, body any) error {
+	if mcli.cache == nil {
+		return mcli.get(endpoint, body)
+	}
+
+	entry := mcli.cache.load(endpoint)
+	resp, raw, err := mcli.request(endpoint, entry.conditionalHeaders())
 	if err != nil {
 		return err
 	}
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("unexpected response (status %v): %v", resp.StatusCode, resp.Status)
+
+	if resp.StatusCode == http.StatusNotModified && entry != nil {
+		log.Println("not modified, using cached:", endpoint)
+		mcli.cache.setChanged(endpoint, false)
+		return decode(entry.body, body)
+	}
+
+	if err := mcli.cache.store(endpoint, raw, resp.Header); err != nil {
+		log.Println("could not cache response:", err)
+	}
+	return decode(raw, body)
+}
+
+// request sends a rate limited request to the endpoint
+// transient failures are retried with an exponential backoff
+func (mcli *MarvelClient) request(endpoint string, headers map[string]string) (*http.Response, []byte, error) {
+	for attempt := 0; ; attempt++ {
+		resp, raw, err := mcli.send(endpoint, headers)
+		if err == nil || !isTemporary(err) || attempt >= mcli.maxRetries {
+			return resp, raw, err
+		}
+
+		backoff := mcli.retryBackoff << attempt
+		log.Printf("request failed (%v), retrying in %v\n", err, backoff)
+		time.Sleep(backoff)
 	}
-	return nil
+}
+
+// send makes a single request and returns the raw body of a successful response
+func (mcli *MarvelClient) send(endpoint string, headers map[string]string) (*http.Response, []byte, error) {
+	mcli.limiter.wait()
+
+	log.Println("sending:", mcli.baseAddr+endpoint)
+	req := sling.New().Client(mcli.http).ResponseDecoder(rawDecoder{}).Set("User-Agent", mcli.userAgent)
+	for key, value := range headers {
+		req = req.Set(key, value)
+	}
+
+	var raw []byte
+	resp, err := req.Get(mcli.baseAddr + endpoint).ReceiveSuccess(&raw)
+	if err != nil {
+		if resp == nil {
+			return nil, nil, &networkError{err: err}
+		}
+		return resp, nil, err
+	}
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotModified {
+		return resp, nil, &StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+	return resp, raw, nil
+}
+
+func decode(raw []byte, body any) error {
+	if len(raw) == 0 {
+		return nil
+	}
+	return json.Unmarshal(raw, body)
+}
+
+// isTemporary reports whether a failed request is worth retrying
+func isTemporary(err error) bool {
+	var ne *networkError
+	var se *StatusError
+	return errors.As(err, &ne) || (errors.As(err, &se) && se.Temporary())
 }
 
 // GetDecklists returns all Decklists posted on Marvelcdb on the specified day
+// returns ErrNoDecklists if there were none
 func (mcli *MarvelClient) GetDecklists(date time.Time) ([]*Decklist, error) {
 	formatted := date.Format("2006-01-02")
 	var decklists []*Decklist
 	err := mcli.get("decklists/by_date/"+formatted, &decklists)
-	return decklists, err
+	return decklists, decklistsError(err)
 }
 
 // GetAllCards returns all the cards on Marvelcdb
 func (mcli *MarvelClient) GetAllCards() ([]*Card, error) {
 	var cards []*Card
-	err := mcli.get("cards", &cards)
+	err := mcli.getCached("cards", &cards)
 	return cards, err
 }
 
 // GetCards returns all cards that are part of the specified pack
 func (mcli *MarvelClient) GetCards(packCode string) ([]*Card, error) {
 	var cards []*Card
-	err := mcli.get("cards/"+packCode, &cards)
+	err := mcli.getCached("cards/"+packCode, &cards)
 	return cards, err
 }
 
 // GetAllPacks returns all the packs on Marvelcdb
 func (mcli *MarvelClient) GetAllPacks() ([]*Pack, error) {
 	var packs []*Pack
-	err := mcli.get("packs", &packs)
+	err := mcli.getCached("packs", &packs)
 	return packs, err
 }
+
+// CatalogueChanged reports whether the packs or cards were different the last time they were fetched
+// without a cache, the catalogue is always considered changed
+func (mcli *MarvelClient) CatalogueChanged() bool {
+	if mcli.cache == nil {
+		return true
+	}
+	return mcli.cache.isChanged("packs") || mcli.cache.isChanged("cards")
+}
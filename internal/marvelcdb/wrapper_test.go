@@ -0,0 +1,194 @@
+package marvel
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestClient returns a MarvelClient for srv that retries quickly and doesn't rate limit
+func newTestClient(t *testing.T, srv *httptest.Server, cacheDir string) *MarvelClient {
+	t.Helper()
+
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	cfg := DefaultConfig()
+	cfg.BaseAddr = srv.URL
+	cfg.RequestInterval = 0
+	cfg.MaxRetries = 2
+	cfg.RetryBackoff = time.Millisecond
+	cfg.CacheDir = cacheDir
+	mcli, err := NewClientWithConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return mcli
+}
+
+func TestRetriesTemporaryFailures(t *testing.T) {
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`[{"code":"core","name":"Core Set"}]`))
+	}))
+	defer srv.Close()
+
+	packs, err := newTestClient(t, srv, "").GetAllPacks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(packs) != 1 || packs[0].Code != "core" {
+		t.Errorf("GetAllPacks() = %+v, want the core set", packs)
+	}
+	if requests != 3 {
+		t.Errorf("sent %v requests, want 3", requests)
+	}
+}
+
+func TestGivesUpAfterMaxRetries(t *testing.T) {
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	_, err := newTestClient(t, srv, "").GetAllPacks()
+	var se *StatusError
+	if !errors.As(err, &se) || se.StatusCode != http.StatusServiceUnavailable || !IsUpstreamError(err) {
+		t.Errorf("GetAllPacks() err = %v, want a 503 StatusError", err)
+	}
+	if requests != 3 {
+		t.Errorf("sent %v requests, want 3", requests)
+	}
+}
+
+func TestDoesNotRetryPermanentFailures(t *testing.T) {
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	if _, err := newTestClient(t, srv, "").GetCards("nope"); err == nil {
+		t.Error("GetCards() succeeded on a 404")
+	}
+	if requests != 1 {
+		t.Errorf("sent %v requests, want 1", requests)
+	}
+}
+
+func TestGetDecklists(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/decklists/by_date/2024-01-10":
+			w.Write([]byte(`[{"id":1,"name":"Aggro Spidey"}]`))
+		case "/decklists/by_date/2024-01-11":
+			// marvelcdb errors on days without decks
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}))
+	defer srv.Close()
+	mcli := newTestClient(t, srv, "")
+
+	decks, err := mcli.GetDecklists(time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC))
+	if err != nil || len(decks) != 1 || decks[0].Id != 1 {
+		t.Errorf("GetDecklists(2024-01-10) = %+v, %v, want deck 1", decks, err)
+	}
+
+	if _, err := mcli.GetDecklists(time.Date(2024, 1, 11, 0, 0, 0, 0, time.UTC)); !errors.Is(err, ErrNoDecklists) {
+		t.Errorf("GetDecklists(2024-01-11) err = %v, want ErrNoDecklists", err)
+	}
+
+	if _, err := mcli.GetDecklists(time.Date(2024, 1, 12, 0, 0, 0, 0, time.UTC)); err == nil || errors.Is(err, ErrNoDecklists) {
+		t.Errorf("GetDecklists(2024-01-12) err = %v, want a StatusError", err)
+	}
+}
+
+func TestLimiterSpacesRequests(t *testing.T) {
+	l := &limiter{interval: time.Millisecond * 20}
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		l.wait()
+	}
+	if elapsed := time.Since(start); elapsed < time.Millisecond*60 {
+		t.Errorf("4 requests took %v, want at least 60ms", elapsed)
+	}
+}
+
+func TestNotModifiedUsesCache(t *testing.T) {
+	var notModified int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			atomic.AddInt32(&notModified, 1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Write([]byte(`[{"code":"core","name":"Core Set"}]`))
+	}))
+	defer srv.Close()
+	mcli := newTestClient(t, srv, t.TempDir())
+
+	for i, wantChanged := range []bool{true, false} {
+		packs, err := mcli.GetAllPacks()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(packs) != 1 || packs[0].Code != "core" {
+			t.Errorf("download %v: GetAllPacks() = %+v, want the core set", i+1, packs)
+		}
+		if _, err := mcli.GetAllCards(); err != nil {
+			t.Fatal(err)
+		}
+		if changed := mcli.CatalogueChanged(); changed != wantChanged {
+			t.Errorf("download %v: CatalogueChanged() = %v, want %v", i+1, changed, wantChanged)
+		}
+	}
+	if notModified != 2 {
+		t.Errorf("server sent %v not modified responses, want 2", notModified)
+	}
+}
+
+func TestIdenticalBodyIsUnchanged(t *testing.T) {
+	body := `[{"code":"core","name":"Core Set"}]`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// no validators, so every request is a full download
+		w.Write([]byte(body))
+	}))
+	defer srv.Close()
+	mcli := newTestClient(t, srv, t.TempDir())
+
+	for i, wantChanged := range []bool{true, false} {
+		if _, err := mcli.GetAllPacks(); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := mcli.GetAllCards(); err != nil {
+			t.Fatal(err)
+		}
+		if changed := mcli.CatalogueChanged(); changed != wantChanged {
+			t.Errorf("download %v: CatalogueChanged() = %v, want %v", i+1, changed, wantChanged)
+		}
+	}
+
+	body = `[{"code":"core","name":"Core Set"},{"code":"hulk","name":"Hulk"}]`
+	if _, err := mcli.GetAllPacks(); err != nil {
+		t.Fatal(err)
+	}
+	if !mcli.CatalogueChanged() {
+		t.Error("CatalogueChanged() = false after the packs changed")
+	}
+}
@@ -1,6 +1,8 @@
 package restserver
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
@@ -10,6 +12,14 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+const (
+	defaultPlanCount = 5
+	maxPlanCount     = 25
+
+	// retryAfterSeconds is how long clients are asked to wait while the valuator is initialising
+	retryAfterSeconds = "30"
+)
+
 var server *Server
 
 type Server struct {
@@ -39,16 +49,56 @@ func Run() {
 	router.GET("/packs", server.GetPacks)
 	router.GET("/pack_values", server.GetAllPackValues)
 	router.GET("/card_values", server.GetAllCardValues)
+	router.GET("/cards/:code/value", server.GetCardValue)
+	router.GET("/purchase_plan", server.GetPurchasePlan)
+	router.GET("/budget_plan", server.GetBudgetPlan)
+	router.GET("/status", server.GetStatus)
+
+	router.POST("/collections", server.CreateCollection)
+	router.GET("/collections/:id", server.GetCollection)
+	router.PUT("/collections/:id", server.UpdateCollection)
+	router.DELETE("/collections/:id", server.DeleteCollection)
+	router.POST("/collections/:id/share", server.ShareCollection)
+	router.GET("/share/:code", server.GetSharedValuation)
+	router.POST("/import", server.ImportCollection)
 
 	router.Run(":9999")
 }
 
+// errorStatus is the status code of each kind of error
+var errorStatus = map[controller.ErrorKind]int{
+	controller.KindInternal:            http.StatusInternalServerError,
+	controller.KindValidation:          http.StatusBadRequest,
+	controller.KindNotFound:            http.StatusNotFound,
+	controller.KindUpstreamUnavailable: http.StatusServiceUnavailable,
+	controller.KindInitialising:        http.StatusServiceUnavailable,
+	controller.KindNotConfigured:       http.StatusNotImplemented,
+}
+
+// respond sends the body, or the error as {"error": message, "kind": kind, "code": code}
 func respond(c *gin.Context, body any, err error) {
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-	} else {
+	if err == nil {
 		c.JSON(http.StatusOK, body)
+		return
+	}
+
+	kind, code := controller.Classify(err)
+	resp := gin.H{"error": err.Error(), "kind": kind, "code": code}
+
+	var unknownPacks *controller.UnknownPacksError
+	if errors.As(err, &unknownPacks) {
+		resp["unknownPacks"] = unknownPacks.Codes
 	}
+	if kind == controller.KindInitialising {
+		c.Header("Retry-After", retryAfterSeconds)
+	}
+
+	c.JSON(errorStatus[kind], resp)
+}
+
+// invalid marks an error in the request as a validation error with the given code
+func invalid(code string, err error) error {
+	return controller.NewError(controller.KindValidation, code, err)
 }
 
 func (s *Server) GetPacks(c *gin.Context) {
@@ -56,55 +106,261 @@ func (s *Server) GetPacks(c *gin.Context) {
 	respond(c, b, err)
 }
 
+func (s *Server) GetStatus(c *gin.Context) {
+	b, err := s.ctrl.GetStatus()
+	respond(c, b, err)
+}
+
 func (s *Server) GetAllPackValues(c *gin.Context) {
-	ownedStr := c.Query("owned")
+	owned, opts, err := s.parseValuationRequest(c)
+	if err != nil {
+		respond(c, nil, err)
+		return
+	}
 
-	aspectWeights := make(map[string]float64)
-	if aggressionWeight := c.Query("aw"); aggressionWeight != "" {
-		f, err := strconv.ParseFloat(aggressionWeight, 64)
-		if err != nil {
-			respond(c, nil, err)
-			return
-		}
+	b, err := s.ctrl.ValueAllPacks(owned, opts)
+	if err != nil {
+		respond(c, nil, err)
+		return
+	}
 
-		aspectWeights["aggression"] = f
+	// sort by value (the default) or value per price
+	err = controller.SortPackValues(b, controller.PackSort(c.Query("sort")))
+	respond(c, b, err)
+}
+
+func (s *Server) GetAllCardValues(c *gin.Context) {
+	owned, opts, err := s.parseValuationRequest(c)
+	if err != nil {
+		respond(c, nil, err)
+		return
 	}
-	if protectionWeight := c.Query("pw"); protectionWeight != "" {
-		f, err := strconv.ParseFloat(protectionWeight, 64)
-		if err != nil {
-			respond(c, nil, err)
+
+	b, err := s.ctrl.ValueAllCards(owned, opts)
+	respond(c, b, err)
+}
+
+func (s *Server) GetCardValue(c *gin.Context) {
+	owned, opts, err := s.parseValuationRequest(c)
+	if err != nil {
+		respond(c, nil, err)
+		return
+	}
+
+	b, err := s.ctrl.ExplainCardValue(c.Param("code"), owned, opts)
+	respond(c, b, err)
+}
+
+func (s *Server) GetPurchasePlan(c *gin.Context) {
+	count := defaultPlanCount
+	if countStr := c.Query("count"); countStr != "" {
+		i, err := strconv.Atoi(countStr)
+		if err != nil || i < 1 || i > maxPlanCount {
+			respond(c, nil, invalid("invalid-count", fmt.Errorf("count must be between 1 and %v", maxPlanCount)))
 			return
 		}
+		count = i
+	}
+
+	owned, opts, err := s.parseValuationRequest(c)
+	if err != nil {
+		respond(c, nil, err)
+		return
+	}
+
+	b, err := s.ctrl.PlanPurchases(owned, count, opts)
+	respond(c, b, err)
+}
+
+func (s *Server) GetBudgetPlan(c *gin.Context) {
+	budget, err := strconv.ParseFloat(c.Query("budget"), 64)
+	if err != nil || budget <= 0 {
+		respond(c, nil, invalid("invalid-budget", fmt.Errorf("budget must be a positive number")))
+		return
+	}
+
+	owned, opts, err := s.parseValuationRequest(c)
+	if err != nil {
+		respond(c, nil, err)
+		return
+	}
+
+	b, err := s.ctrl.SuggestBudgetPlan(owned, budget, opts)
+	respond(c, b, err)
+}
+
+func (s *Server) GetCollection(c *gin.Context) {
+	b, err := s.ctrl.GetCollection(c.Param("id"))
+	respond(c, b, err)
+}
+
+func (s *Server) CreateCollection(c *gin.Context) {
+	coll := &controller.Collection{}
+	if err := c.ShouldBindJSON(coll); err != nil {
+		respond(c, nil, invalid("invalid-body", err))
+		return
+	}
+
+	b, err := s.ctrl.CreateCollection(coll)
+	respond(c, b, err)
+}
 
-		aspectWeights["protection"] = f
+func (s *Server) UpdateCollection(c *gin.Context) {
+	coll := &controller.Collection{}
+	if err := c.ShouldBindJSON(coll); err != nil {
+		respond(c, nil, invalid("invalid-body", err))
+		return
 	}
-	if leadershipWeight := c.Query("lw"); leadershipWeight != "" {
-		f, err := strconv.ParseFloat(leadershipWeight, 64)
+
+	b, err := s.ctrl.UpdateCollection(c.Param("id"), coll)
+	respond(c, b, err)
+}
+
+func (s *Server) DeleteCollection(c *gin.Context) {
+	err := s.ctrl.DeleteCollection(c.Param("id"))
+	respond(c, gin.H{"deleted": c.Param("id")}, err)
+}
+
+func (s *Server) ShareCollection(c *gin.Context) {
+	b, err := s.ctrl.ShareCollection(c.Param("id"))
+	respond(c, b, err)
+}
+
+func (s *Server) GetSharedValuation(c *gin.Context) {
+	opts, err := parseValuationOptions(c)
+	if err != nil {
+		respond(c, nil, err)
+		return
+	}
+
+	b, err := s.ctrl.ValueSharedCollection(c.Param("code"), opts)
+	if err != nil {
+		respond(c, nil, err)
+		return
+	}
+
+	err = controller.SortPackValues(b.PackValues, controller.PackSort(c.Query("sort")))
+	respond(c, b, err)
+}
+
+func (s *Server) ImportCollection(c *gin.Context) {
+	data, err := c.GetRawData()
+	if err != nil {
+		respond(c, nil, invalid("invalid-body", err))
+		return
+	}
+
+	req, err := controller.ParseImport(data)
+	if err != nil {
+		respond(c, nil, err)
+		return
+	}
+
+	b, err := s.ctrl.ImportCollection(req)
+	respond(c, b, err)
+}
+
+// parseValuationRequest reads the owned packs (owned) and the valuation options from the query
+// if a saved collection is given (collection), it fills in anything the query doesn't set
+func (s *Server) parseValuationRequest(c *gin.Context) ([]string, controller.ValuationOptions, error) {
+	var owned []string
+	if ownedStr := c.Query("owned"); ownedStr != "" {
+		owned = strings.Split(ownedStr, ",")
+	}
+
+	opts, err := parseValuationOptions(c)
+	if err != nil {
+		return nil, opts, err
+	}
+
+	if id := c.Query("collection"); id != "" {
+		coll, err := s.ctrl.GetCollection(id)
 		if err != nil {
-			respond(c, nil, err)
-			return
+			return nil, opts, err
 		}
+		owned, opts = coll.Apply(owned, opts)
+	}
+
+	return owned, opts, nil
+}
 
-		aspectWeights["leadership"] = f
+// parseValuationOptions reads the weights, the popularity half life (half_life, in months)
+// and the hero to value for (hero) from the query
+func parseValuationOptions(c *gin.Context) (controller.ValuationOptions, error) {
+	opts := controller.ValuationOptions{}
+
+	weights, err := parseWeights(c)
+	if err != nil {
+		return opts, invalid("invalid-weight", err)
 	}
-	if justiceWeight := c.Query("jw"); justiceWeight != "" {
-		f, err := strconv.ParseFloat(justiceWeight, 64)
-		if err != nil {
-			respond(c, nil, err)
-			return
+	opts.Weights = weights
+
+	if halfLife := c.Query("half_life"); halfLife != "" {
+		f, err := strconv.ParseFloat(halfLife, 64)
+		if err != nil || f < 0 {
+			return opts, invalid("invalid-half-life", fmt.Errorf("half_life must be a number of months"))
 		}
+		opts.PopularityHalfLife = &f
+	}
+	opts.HeroCode = c.Query("hero")
+
+	return opts, nil
+}
 
-		aspectWeights["justice"] = f
+// parseWeights reads the aspect weights (aw, pw, lw, jw) and the type, trait and card weights
+// (type_weights, trait_weights, card_weights as "key:weight,key:weight") from the query
+func parseWeights(c *gin.Context) (*controller.Weights, error) {
+	weights := &controller.Weights{Aspects: make(map[string]float64)}
+
+	aspectParams := map[string]string{"aw": "aggression", "pw": "protection", "lw": "leadership", "jw": "justice"}
+	for param, aspect := range aspectParams {
+		if weight := c.Query(param); weight != "" {
+			f, err := strconv.ParseFloat(weight, 64)
+			if err != nil {
+				return nil, fmt.Errorf("%v must be a number", param)
+			}
+
+			weights.Aspects[aspect] = f
+		}
 	}
 
-	owned := strings.Split(ownedStr, ",")
-	b, err := s.ctrl.ValueAllPacks(owned, aspectWeights)
-	respond(c, b, err)
+	var err error
+	if weights.Types, err = parseWeightList(c.Query("type_weights")); err != nil {
+		return nil, err
+	}
+	if weights.Traits, err = parseWeightList(c.Query("trait_weights")); err != nil {
+		return nil, err
+	}
+	if weights.Cards, err = parseWeightList(c.Query("card_weights")); err != nil {
+		return nil, err
+	}
+
+	// no weights given
+	if len(weights.Aspects)+len(weights.Types)+len(weights.Traits)+len(weights.Cards) == 0 {
+		return nil, nil
+	}
+
+	return weights, nil
 }
 
-func (s *Server) GetAllCardValues(c *gin.Context) {
-	ownedStr := c.Query("owned")
-	owned := strings.Split(ownedStr, ",")
-	b, err := s.ctrl.ValueAllCards(owned)
-	respond(c, b, err)
+// parseWeightList parses "key:weight,key:weight" into a map
+func parseWeightList(str string) (map[string]float64, error) {
+	weights := make(map[string]float64)
+	for _, pair := range strings.Split(str, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+
+		key, weight, ok := strings.Cut(pair, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid weight %q, expected key:weight", pair)
+		}
+		f, err := strconv.ParseFloat(strings.TrimSpace(weight), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid weight %q, expected key:weight", pair)
+		}
+
+		weights[strings.TrimSpace(key)] = f
+	}
+	return weights, nil
 }
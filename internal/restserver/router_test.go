@@ -0,0 +1,49 @@
+package restserver
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/colbymilton/marchamps-valuator/internal/controller"
+	"github.com/gin-gonic/gin"
+)
+
+func TestRespondErrors(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name       string
+		err        error
+		status     int
+		code       string
+		retryAfter string
+	}{
+		{"no prices", controller.ErrNoPrices, http.StatusNotImplemented, "no-prices", ""},
+		{"initialising", controller.ErrInitialising, http.StatusServiceUnavailable, "initialising", retryAfterSeconds},
+		{"validation", invalid("invalid-budget", errors.New("bad budget")), http.StatusBadRequest, "invalid-budget", ""},
+		{"unknown packs", &controller.UnknownPacksError{Codes: []string{"nope"}}, http.StatusBadRequest, "unknown-packs", ""},
+		{"internal", errors.New("broken"), http.StatusInternalServerError, "internal", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			respond(c, nil, tt.err)
+
+			body := map[string]any{}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if w.Code != tt.status || body["code"] != tt.code {
+				t.Errorf("status %v code %v, want %v %v", w.Code, body["code"], tt.status, tt.code)
+			}
+			if retryAfter := w.Header().Get("Retry-After"); retryAfter != tt.retryAfter {
+				t.Errorf("Retry-After = %q, want %q", retryAfter, tt.retryAfter)
+			}
+		})
+	}
+}
@@ -0,0 +1,209 @@
+package boltWrapper
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"go.etcd.io/bbolt"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Filter reports whether a thing should be included in a result
+type Filter[T any] func(thing *T) bool
+
+// Less reports whether a should be sorted before b
+type Less[T any] func(a, b *T) bool
+
+// BoltDB is a file-backed document store that mirrors the mongoWrapper operations.
+// Every collection is a bucket of bson encoded documents keyed by their "_id".
+type BoltDB struct {
+	db *bbolt.DB
+}
+
+func NewBoltDB(path string) *BoltDB {
+	db, err := bbolt.Open(path, 0600, &bbolt.Options{Timeout: time.Second * 10})
+	if err != nil {
+		log.Fatalf("could not open bolt database %q: %v\n", path, err)
+	}
+
+	return &BoltDB{db: db}
+}
+
+func (bdb *BoltDB) Close() error {
+	return bdb.db.Close()
+}
+
+func (bdb *BoltDB) Ping() error {
+	return bdb.db.View(func(tx *bbolt.Tx) error { return nil })
+}
+
+func (bdb *BoltDB) EmptyCollection(coll string) {
+	bdb.db.Update(func(tx *bbolt.Tx) error {
+		if tx.Bucket([]byte(coll)) == nil {
+			return nil
+		}
+		return tx.DeleteBucket([]byte(coll))
+	})
+}
+
+func (bdb *BoltDB) GetCollectionSize(coll string) int {
+	size := 0
+	bdb.db.View(func(tx *bbolt.Tx) error {
+		if b := tx.Bucket([]byte(coll)); b != nil {
+			size = b.Stats().KeyN
+		}
+		return nil
+	})
+	return size
+}
+
+func encode(thing any) ([]byte, []byte, error) {
+	doc, err := bson.Marshal(thing)
+	if err != nil {
+		return nil, nil, err
+	}
+	id, err := bson.Raw(doc).LookupErr("_id")
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not find the _id field")
+	}
+	return []byte(id.String()), doc, nil
+}
+
+// CreateMany will insert multiple documents into the database
+// if a document with a matching "_id" already exists, it is ignored
+func CreateMany[T any](bdb *BoltDB, coll string, things []*T) error {
+	return bdb.db.Update(func(tx *bbolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(coll))
+		if err != nil {
+			return err
+		}
+
+		for _, thing := range things {
+			id, doc, err := encode(thing)
+			if err != nil {
+				return err
+			}
+			if b.Get(id) != nil {
+				continue
+			}
+			if err := b.Put(id, doc); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ReplaceManyID will replace multiple documents in the database that have a matching "_id"
+// if there is no matching document, it is inserted
+func ReplaceManyID[T any](bdb *BoltDB, coll string, things []*T) error {
+	return bdb.db.Update(func(tx *bbolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(coll))
+		if err != nil {
+			return err
+		}
+
+		for _, thing := range things {
+			id, doc, err := encode(thing)
+			if err != nil {
+				return err
+			}
+			if err := b.Put(id, doc); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ReplaceOneID will replace a document in the database that has a matching "_id"
+// if there is no matching document, it is inserted
+func ReplaceOneID[T any](bdb *BoltDB, coll string, thing *T) error {
+	return ReplaceManyID(bdb, coll, []*T{thing})
+}
+
+// DeleteMany removes the documents from the collection that pass the filter
+// returns how many documents were removed
+func DeleteMany[T any](bdb *BoltDB, coll string, filter Filter[T]) (int, error) {
+	deleted := 0
+	err := bdb.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(coll))
+		if b == nil {
+			return nil
+		}
+
+		// collect the keys first, a bucket can't be modified while iterating over it
+		keys := [][]byte{}
+		err := b.ForEach(func(k, v []byte) error {
+			var next *T
+			if err := bson.Unmarshal(v, &next); err != nil {
+				return err
+			}
+			if filter(next) {
+				keys = append(keys, append([]byte{}, k...))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, key := range keys {
+			if err := b.Delete(key); err != nil {
+				return err
+			}
+		}
+		deleted = len(keys)
+		return nil
+	})
+	return deleted, err
+}
+
+// GetMany returns a slice of T objects from the specified collection
+// that pass the filter, sorted by less. A nil filter or less is ignored.
+func GetMany[T any](bdb *BoltDB, coll string, filter Filter[T], less Less[T]) ([]*T, error) {
+	things := make([]*T, 0)
+	err := bdb.db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(coll))
+		if b == nil {
+			return nil
+		}
+
+		return b.ForEach(func(_, doc []byte) error {
+			var next *T
+			if err := bson.Unmarshal(doc, &next); err != nil {
+				return err
+			}
+			if filter == nil || filter(next) {
+				things = append(things, next)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if less != nil {
+		sort.SliceStable(things, func(i, j int) bool { return less(things[i], things[j]) })
+	}
+
+	return things, nil
+}
+
+// GetAll returns a slice of T objects from the specified collection without filters or sorting
+func GetAll[T any](bdb *BoltDB, coll string) ([]*T, error) {
+	return GetMany[T](bdb, coll, nil, nil)
+}
+
+// GetOne returns the first thing that passes the filter after sorting
+// returns nil, nil if there isn't one thing to return
+func GetOne[T any](bdb *BoltDB, coll string, filter Filter[T], less Less[T]) (*T, error) {
+	things, err := GetMany(bdb, coll, filter, less)
+	if err != nil || len(things) == 0 {
+		return nil, err
+	}
+	return things[0], nil
+}
@@ -0,0 +1,168 @@
+package boltWrapper
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+type thing struct {
+	Id    string `bson:"_id"`
+	Name  string
+	Count int
+}
+
+// numbered things are keyed by an integer "_id", like stored decks
+type numbered struct {
+	Id   int `bson:"_id"`
+	Name string
+}
+
+func names(things []*thing) []string {
+	ns := []string{}
+	for _, t := range things {
+		ns = append(ns, t.Name)
+	}
+	return ns
+}
+
+func newTestDB(t *testing.T) (*BoltDB, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "test.db")
+	bdb := NewBoltDB(path)
+	t.Cleanup(func() { bdb.Close() })
+	return bdb, path
+}
+
+func TestCreateManyIgnoresExisting(t *testing.T) {
+	bdb, _ := newTestDB(t)
+	if err := CreateMany(bdb, "things", []*thing{{Id: "a", Name: "first"}, {Id: "b", Name: "second"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := CreateMany(bdb, "things", []*thing{{Id: "a", Name: "replaced"}, {Id: "c", Name: "third"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := GetOne(bdb, "things", func(t *thing) bool { return t.Id == "a" }, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "first" {
+		t.Errorf("CreateMany replaced an existing document: got %q, want %q", got.Name, "first")
+	}
+	if size := bdb.GetCollectionSize("things"); size != 3 {
+		t.Errorf("collection size = %v, want 3", size)
+	}
+}
+
+func TestReplaceOneIDUpserts(t *testing.T) {
+	bdb, _ := newTestDB(t)
+	if err := ReplaceOneID(bdb, "things", &thing{Id: "a", Name: "first"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ReplaceOneID(bdb, "things", &thing{Id: "a", Name: "replaced"}); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := GetAll[thing](bdb, "things")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Name != "replaced" {
+		t.Errorf("ReplaceOneID: got %q, want [replaced]", names(got))
+	}
+}
+
+func TestIntegerIds(t *testing.T) {
+	bdb, _ := newTestDB(t)
+	if err := CreateMany(bdb, "numbered", []*numbered{{Id: 1, Name: "one"}, {Id: 10, Name: "ten"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := CreateMany(bdb, "numbered", []*numbered{{Id: 1, Name: "again"}, {Id: 2, Name: "two"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := GetMany[numbered](bdb, "numbered", nil, func(a, b *numbered) bool { return a.Id < b.Id })
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*numbered{{Id: 1, Name: "one"}, {Id: 2, Name: "two"}, {Id: 10, Name: "ten"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GetMany = %+v, want %+v", got, want)
+	}
+}
+
+func TestGetManyFiltersAndSorts(t *testing.T) {
+	bdb, _ := newTestDB(t)
+	things := []*thing{{Id: "a", Name: "a", Count: 2}, {Id: "b", Name: "b", Count: 5}, {Id: "c", Name: "c", Count: 1}, {Id: "d", Name: "d", Count: 4}}
+	if err := CreateMany(bdb, "things", things); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := GetMany(bdb, "things", func(t *thing) bool { return t.Count > 1 }, func(a, b *thing) bool { return a.Count > b.Count })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"b", "d", "a"}; !reflect.DeepEqual(names(got), want) {
+		t.Errorf("GetMany = %q, want %q", names(got), want)
+	}
+
+	none, err := GetOne[thing](bdb, "missing", nil, nil)
+	if err != nil || none != nil {
+		t.Errorf("GetOne on a missing bucket = %v, %v, want nil, nil", none, err)
+	}
+
+	// an emptied collection has no bucket left
+	bdb.EmptyCollection("things")
+	none, err = GetOne[thing](bdb, "things", nil, nil)
+	if err != nil || none != nil {
+		t.Errorf("GetOne on an emptied bucket = %v, %v, want nil, nil", none, err)
+	}
+}
+
+func TestDeleteMany(t *testing.T) {
+	bdb, _ := newTestDB(t)
+	if err := CreateMany(bdb, "things", []*thing{{Id: "a", Count: 1}, {Id: "b", Count: 2}, {Id: "c", Count: 3}}); err != nil {
+		t.Fatal(err)
+	}
+
+	deleted, err := DeleteMany(bdb, "things", func(t *thing) bool { return t.Count >= 2 })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted != 2 {
+		t.Errorf("DeleteMany deleted %v, want 2", deleted)
+	}
+	got, err := GetAll[thing](bdb, "things")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Id != "a" {
+		t.Errorf("after DeleteMany: got %+v, want only a", got)
+	}
+
+	deleted, err = DeleteMany(bdb, "missing", func(t *thing) bool { return true })
+	if err != nil || deleted != 0 {
+		t.Errorf("DeleteMany on a missing bucket = %v, %v, want 0, nil", deleted, err)
+	}
+}
+
+func TestDocumentsPersist(t *testing.T) {
+	bdb, path := newTestDB(t)
+	if err := ReplaceOneID(bdb, "things", &thing{Id: "a", Name: "kept", Count: 3}); err != nil {
+		t.Fatal(err)
+	}
+	if err := bdb.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened := NewBoltDB(path)
+	defer reopened.Close()
+	got, err := GetAll[thing](reopened, "things")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []*thing{{Id: "a", Name: "kept", Count: 3}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("after reopening: got %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,200 @@
+package memoryWrapper
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Filter reports whether a thing should be included in a result
+type Filter[T any] func(thing *T) bool
+
+// Less reports whether a should be sorted before b
+type Less[T any] func(a, b *T) bool
+
+type collection struct {
+	keys []string
+	docs map[string][]byte
+}
+
+// MemoryDB is an in-memory document store that mirrors the mongoWrapper operations.
+// Documents are stored bson encoded so that callers never share memory with the store.
+type MemoryDB struct {
+	colls map[string]*collection
+	mutex sync.RWMutex
+}
+
+func NewMemoryDB() *MemoryDB {
+	return &MemoryDB{colls: make(map[string]*collection)}
+}
+
+func (mdb *MemoryDB) Ping() error {
+	return nil
+}
+
+func (mdb *MemoryDB) EmptyCollection(coll string) {
+	mdb.mutex.Lock()
+	defer mdb.mutex.Unlock()
+
+	delete(mdb.colls, coll)
+}
+
+func (mdb *MemoryDB) GetCollectionSize(coll string) int {
+	mdb.mutex.RLock()
+	defer mdb.mutex.RUnlock()
+
+	if c, ok := mdb.colls[coll]; ok {
+		return len(c.keys)
+	}
+	return 0
+}
+
+// collection returns the named collection, creating it if needed
+// the caller must hold the write lock
+func (mdb *MemoryDB) collection(coll string) *collection {
+	c, ok := mdb.colls[coll]
+	if !ok {
+		c = &collection{docs: make(map[string][]byte)}
+		mdb.colls[coll] = c
+	}
+	return c
+}
+
+func encode(thing any) (string, []byte, error) {
+	doc, err := bson.Marshal(thing)
+	if err != nil {
+		return "", nil, err
+	}
+	id, err := bson.Raw(doc).LookupErr("_id")
+	if err != nil {
+		return "", nil, fmt.Errorf("could not find the _id field")
+	}
+	return id.String(), doc, nil
+}
+
+// CreateMany will insert multiple documents into the database
+// if a document with a matching "_id" already exists, it is ignored
+func CreateMany[T any](mdb *MemoryDB, coll string, things []*T) error {
+	mdb.mutex.Lock()
+	defer mdb.mutex.Unlock()
+
+	c := mdb.collection(coll)
+	for _, thing := range things {
+		id, doc, err := encode(thing)
+		if err != nil {
+			return err
+		}
+		if _, ok := c.docs[id]; ok {
+			continue
+		}
+		c.keys = append(c.keys, id)
+		c.docs[id] = doc
+	}
+
+	return nil
+}
+
+// ReplaceManyID
+func ReplaceManyID[T any](mdb *MemoryDB, coll string, things []*T) error {
+	for _, thing := range things {
+		if err := ReplaceOneID[T](mdb, coll, thing); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReplaceOneID will replace a document in the database that has a matching "_id"
+// if there is no matching document, it is inserted
+func ReplaceOneID[T any](mdb *MemoryDB, coll string, thing *T) error {
+	id, doc, err := encode(thing)
+	if err != nil {
+		return err
+	}
+
+	mdb.mutex.Lock()
+	defer mdb.mutex.Unlock()
+
+	c := mdb.collection(coll)
+	if _, ok := c.docs[id]; !ok {
+		c.keys = append(c.keys, id)
+	}
+	c.docs[id] = doc
+
+	return nil
+}
+
+// DeleteMany removes the documents from the collection that pass the filter
+// returns how many documents were removed
+func DeleteMany[T any](mdb *MemoryDB, coll string, filter Filter[T]) (int, error) {
+	mdb.mutex.Lock()
+	defer mdb.mutex.Unlock()
+
+	c, ok := mdb.colls[coll]
+	if !ok {
+		return 0, nil
+	}
+
+	keys := make([]string, 0, len(c.keys))
+	for _, key := range c.keys {
+		var next *T
+		if err := bson.Unmarshal(c.docs[key], &next); err != nil {
+			return 0, err
+		}
+		if filter(next) {
+			delete(c.docs, key)
+		} else {
+			keys = append(keys, key)
+		}
+	}
+
+	deleted := len(c.keys) - len(keys)
+	c.keys = keys
+	return deleted, nil
+}
+
+// GetMany returns a slice of T objects from the specified collection
+// that pass the filter, sorted by less. A nil filter or less is ignored.
+func GetMany[T any](mdb *MemoryDB, coll string, filter Filter[T], less Less[T]) ([]*T, error) {
+	mdb.mutex.RLock()
+	defer mdb.mutex.RUnlock()
+
+	things := make([]*T, 0)
+	c, ok := mdb.colls[coll]
+	if !ok {
+		return things, nil
+	}
+
+	for _, key := range c.keys {
+		var next *T
+		if err := bson.Unmarshal(c.docs[key], &next); err != nil {
+			return nil, err
+		}
+		if filter == nil || filter(next) {
+			things = append(things, next)
+		}
+	}
+
+	if less != nil {
+		sort.SliceStable(things, func(i, j int) bool { return less(things[i], things[j]) })
+	}
+
+	return things, nil
+}
+
+// GetAll returns a slice of T objects from the specified collection without filters or sorting
+func GetAll[T any](mdb *MemoryDB, coll string) ([]*T, error) {
+	return GetMany[T](mdb, coll, nil, nil)
+}
+
+// GetOne returns the first thing that passes the filter after sorting
+// returns nil, nil if there isn't one thing to return
+func GetOne[T any](mdb *MemoryDB, coll string, filter Filter[T], less Less[T]) (*T, error) {
+	things, err := GetMany(mdb, coll, filter, less)
+	if err != nil || len(things) == 0 {
+		return nil, err
+	}
+	return things[0], nil
+}
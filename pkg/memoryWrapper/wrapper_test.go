@@ -0,0 +1,105 @@
+package memoryWrapper
+
+import (
+	"reflect"
+	"testing"
+)
+
+type thing struct {
+	Id    string `bson:"_id"`
+	Name  string
+	Count int
+}
+
+func names(things []*thing) []string {
+	ns := []string{}
+	for _, t := range things {
+		ns = append(ns, t.Name)
+	}
+	return ns
+}
+
+func TestCreateManyIgnoresExisting(t *testing.T) {
+	mdb := NewMemoryDB()
+	if err := CreateMany(mdb, "things", []*thing{{Id: "a", Name: "first"}, {Id: "b", Name: "second"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := CreateMany(mdb, "things", []*thing{{Id: "a", Name: "replaced"}, {Id: "c", Name: "third"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := GetOne(mdb, "things", func(t *thing) bool { return t.Id == "a" }, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "first" {
+		t.Errorf("CreateMany replaced an existing document: got %q, want %q", got.Name, "first")
+	}
+	if size := mdb.GetCollectionSize("things"); size != 3 {
+		t.Errorf("collection size = %v, want 3", size)
+	}
+}
+
+func TestReplaceOneIDUpserts(t *testing.T) {
+	mdb := NewMemoryDB()
+	if err := ReplaceOneID(mdb, "things", &thing{Id: "a", Name: "first"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ReplaceOneID(mdb, "things", &thing{Id: "a", Name: "replaced"}); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := GetAll[thing](mdb, "things")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Name != "replaced" {
+		t.Errorf("ReplaceOneID: got %q, want [replaced]", names(got))
+	}
+}
+
+func TestGetManyFiltersAndSorts(t *testing.T) {
+	mdb := NewMemoryDB()
+	things := []*thing{{Id: "a", Name: "a", Count: 2}, {Id: "b", Name: "b", Count: 5}, {Id: "c", Name: "c", Count: 1}, {Id: "d", Name: "d", Count: 4}}
+	if err := CreateMany(mdb, "things", things); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := GetMany(mdb, "things", func(t *thing) bool { return t.Count > 1 }, func(a, b *thing) bool { return a.Count > b.Count })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"b", "d", "a"}; !reflect.DeepEqual(names(got), want) {
+		t.Errorf("GetMany = %q, want %q", names(got), want)
+	}
+
+	// changes to returned things don't reach the store
+	got[0].Name = "changed"
+	again, _ := GetOne(mdb, "things", func(t *thing) bool { return t.Id == "b" }, nil)
+	if again.Name != "b" {
+		t.Errorf("stored document was changed through a returned thing: %q", again.Name)
+	}
+
+	none, err := GetOne[thing](mdb, "missing", nil, nil)
+	if err != nil || none != nil {
+		t.Errorf("GetOne on a missing collection = %v, %v, want nil, nil", none, err)
+	}
+}
+
+func TestDeleteMany(t *testing.T) {
+	mdb := NewMemoryDB()
+	if err := CreateMany(mdb, "things", []*thing{{Id: "a", Count: 1}, {Id: "b", Count: 2}, {Id: "c", Count: 3}}); err != nil {
+		t.Fatal(err)
+	}
+
+	deleted, err := DeleteMany(mdb, "things", func(t *thing) bool { return t.Count >= 2 })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted != 2 {
+		t.Errorf("DeleteMany deleted %v, want 2", deleted)
+	}
+	if size := mdb.GetCollectionSize("things"); size != 1 {
+		t.Errorf("collection size = %v, want 1", size)
+	}
+}
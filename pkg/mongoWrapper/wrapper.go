@@ -23,7 +23,9 @@ type MongoDB struct {
 func NewMongoDB(uri, database string) *MongoDB {
 	mdb := &MongoDB{}
 
-	cli, err := mongo.Connect(defaultContext(), options.Client().ApplyURI(uri))
+	ctx, cancel := defaultContext()
+	defer cancel()
+	cli, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
 	if err != nil {
 		log.Fatalln(err)
 	}
@@ -32,9 +34,8 @@ func NewMongoDB(uri, database string) *MongoDB {
 	return mdb
 }
 
-func defaultContext() context.Context {
-	ctx, _ := context.WithTimeout(context.Background(), time.Second*10)
-	return ctx
+func defaultContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), time.Second*10)
 }
 
 func findBsonField(thing interface{}, fieldName string) (interface{}, error) {
@@ -59,15 +60,21 @@ func findBsonField(thing interface{}, fieldName string) (interface{}, error) {
 }
 
 func (mdb *MongoDB) Ping() error {
-	return mdb.db.Client().Ping(defaultContext(), nil)
+	ctx, cancel := defaultContext()
+	defer cancel()
+	return mdb.db.Client().Ping(ctx, nil)
 }
 
 func (mdb *MongoDB) EmptyCollection(coll string) {
-	mdb.db.Collection(coll).Drop(defaultContext())
+	ctx, cancel := defaultContext()
+	defer cancel()
+	mdb.db.Collection(coll).Drop(ctx)
 }
 
 func (mdb *MongoDB) GetCollectionSize(coll string) int {
-	result := mdb.db.RunCommand(defaultContext(), bson.M{"collStats": coll})
+	ctx, cancel := defaultContext()
+	defer cancel()
+	result := mdb.db.RunCommand(ctx, bson.M{"collStats": coll})
 	var document bson.M
 	if err := result.Decode(&document); err != nil {
 		return -1
@@ -85,7 +92,9 @@ func CreateMany[T any](mdb *MongoDB, coll string, things []*T) error {
 	collection := mdb.db.Collection(coll)
 
 	for _, thing := range things {
-		_, err := collection.InsertOne(defaultContext(), thing)
+		ctx, cancel := defaultContext()
+		_, err := collection.InsertOne(ctx, thing)
+		cancel()
 		if err != nil && !mongo.IsDuplicateKeyError(err) {
 			return err
 		}
@@ -119,7 +128,9 @@ func ReplaceOne[T any](mdb *MongoDB, coll string, filter bson.D, thing *T) error
 	collection := mdb.db.Collection(coll)
 
 	opts := options.Replace().SetUpsert(true)
-	_, err := collection.ReplaceOne(defaultContext(), filter, thing, opts)
+	ctx, cancel := defaultContext()
+	defer cancel()
+	_, err := collection.ReplaceOne(ctx, filter, thing, opts)
 	return err
 }
 
@@ -135,13 +146,29 @@ func ReplaceOneID[T any](mdb *MongoDB, coll string, thing *T) error {
 	return ReplaceOne[T](mdb, coll, filter, thing)
 }
 
+// DeleteMany removes the documents from the collection that match the filter
+// returns how many documents were removed
+func DeleteMany(mdb *MongoDB, coll string, filter bson.D) (int, error) {
+	collection := mdb.db.Collection(coll)
+
+	ctx, cancel := defaultContext()
+	defer cancel()
+	res, err := collection.DeleteMany(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	return int(res.DeletedCount), nil
+}
+
 // GetMany returns a slice of T objects that
 // are retrieved from the specified database, collection, sort, and filter
 func GetMany[T any](mdb *MongoDB, coll string, filter bson.D, sort bson.M) ([]*T, error) {
 	things := make([]*T, 0)
 	collection := mdb.db.Collection(coll)
 	opts := options.Find().SetSort(sort)
-	cur, err := collection.Find(defaultContext(), filter, opts)
+	ctx, cancel := defaultContext()
+	defer cancel()
+	cur, err := collection.Find(ctx, filter, opts)
 	if err != nil {
 		return nil, err
 	}
@@ -169,12 +196,14 @@ func GetAll[T any](mdb *MongoDB, coll string) ([]*T, error) {
 func GetOne[T any](mdb *MongoDB, coll string, filter bson.D, sort bson.M) (*T, error) {
 	collection := mdb.db.Collection(coll)
 	opts := options.Find().SetSort(sort).SetLimit(1)
-	cur, err := collection.Find(defaultContext(), filter, opts)
+	ctx, cancel := defaultContext()
+	defer cancel()
+	cur, err := collection.Find(ctx, filter, opts)
 	if err != nil {
 		return nil, err
 	}
-	defer cur.Close(defaultContext())
-	for cur.Next(defaultContext()) {
+	defer cur.Close(ctx)
+	for cur.Next(ctx) {
 		var thing *T
 		err := cur.Decode(&thing)
 		if err != nil {